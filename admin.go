@@ -0,0 +1,368 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FeedSize - # of recent touch messages kept for the admin vote feed
+const FeedSize = 32
+
+// admnFS - static dashboard assets served at /admin
+//
+//go:embed admin
+var admnFS embed.FS
+
+// LmpStts - udp send status of a single lamp
+type LmpStts struct {
+	LstSnd int64   `json:"last_send"`  // time of last successful send in ms
+	LstErr string  `json:"last_error"` // last send error or empty
+	Snt    int64   `json:"sent"`       // # of packets sent
+	Fld    int64   `json:"failed"`     // # of failed sends
+	Draw   float64 `json:"draw"`       // estimated mA of last frame before limiting
+	Scl    float64 `json:"scale"`      // power limiter brightness scale of last frame
+	Lmtd   int64   `json:"limited"`    // # of frames limited
+}
+
+// PwrStts - estimated current draw & how often the power limiter kicks in
+type PwrStts struct {
+	Draw   float64 `json:"draw"`     // estimated mA of last frame before limiting
+	Out    float64 `json:"out"`      // estimated mA of last frame as sent
+	MxDraw float64 `json:"max_draw"` // highest estimate before limiting
+	Frms   int64   `json:"frames"`   // # of frames estimated
+	Lmtd   int64   `json:"limited"`  // # of frames with any lamp limited
+	LmpMA  float64 `json:"lamp_ma"`  // budgets, 0 if unlimited
+	TtlMA  float64 `json:"total_ma"`
+}
+
+// FrmStts - timing of blnkr frame updates
+type FrmStts struct {
+	Cnt    int64   `json:"count"`    // # of frames rendered
+	Intrvl float64 `json:"interval"` // smoothed ms between frames
+	Rndr   float64 `json:"render"`   // smoothed ms to render & send a frame
+	MxRndr float64 `json:"max_render"`
+}
+
+// FdMsg - touch message with receive time for the admin vote feed
+type FdMsg struct {
+	DataMsg
+	Time int64 `json:"time"`
+}
+
+// Stts - shared snapshot of server state for the admin dashboard
+type Stts struct {
+	mu    sync.Mutex
+	Start int64               `json:"start"`
+	Beats map[int]int64       `json:"beats"` // last heartbeat time per vote station
+	Lmps  map[string]*LmpStts `json:"lamps"`
+	Wrds  []DataMsg           `json:"words"` // current words per station slot
+	Feed  []FdMsg             `json:"feed"`  // recent touches, newest last
+	Frm   FrmStts             `json:"frame"`
+	Pwr   PwrStts             `json:"power"`
+	Md    string              `json:"mode"`      // current blnkr mode
+	Rec   string              `json:"recording"` // file frames are recorded to or empty
+	Pool  []PoolWrd           `json:"-"`         // live word pool incl disabled words, served on its own
+	Rnd   *RndStts            `json:"round"`     // current round, null without rounds
+	Idle  bool                `json:"idle"`      // blnkr faded to attract, no touches for a while
+	Shw   ShwStts             `json:"show"`      // show program running & why
+	Scn   string              `json:"scene"`     // current scene, empty for default
+	Scns  []string            `json:"scenes"`    // scene names to switch to
+}
+
+// NewStts - init status with list of vote station sources
+func NewStts(srcs []int) *Stts {
+	s := Stts{
+		Start: NowMs(),
+		Beats: make(map[int]int64),
+		Lmps:  make(map[string]*LmpStts),
+		Md:    BlnkRun,
+	}
+	for _, src := range srcs {
+		s.Beats[src] = 0
+	}
+	return &s
+}
+
+// Beat - record heartbeat from vote station
+func (s *Stts) Beat(src int, stmp int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Beats[src] = stmp
+}
+
+// LmpSnt - record result of udp send to lamp
+func (s *Stts) LmpSnt(ip string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, has := s.Lmps[ip]
+	if !has {
+		ls = &LmpStts{}
+		s.Lmps[ip] = ls
+	}
+	if err != nil {
+		ls.Fld++
+		ls.LstErr = err.Error()
+		return
+	}
+	ls.Snt++
+	ls.LstSnd = NowMs()
+	ls.LstErr = ""
+}
+
+// Frame - record frame interval & render duration
+func (s *Stts) Frame(intrvl, rndr time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := float64(intrvl) / float64(time.Millisecond)
+	r := float64(rndr) / float64(time.Millisecond)
+	if s.Frm.Cnt == 0 {
+		s.Frm.Intrvl, s.Frm.Rndr = i, r
+	} else { // exponential smoothing so the dashboard doesnt flicker
+		s.Frm.Intrvl += 0.05 * (i - s.Frm.Intrvl)
+		s.Frm.Rndr += 0.05 * (r - s.Frm.Rndr)
+	}
+	if r > s.Frm.MxRndr {
+		s.Frm.MxRndr = r
+	}
+	s.Frm.Cnt++
+}
+
+// LmpPwr - record estimated draw & limiter scale of lamp for a frame
+func (s *Stts) LmpPwr(ip string, draw float64, scl float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, has := s.Lmps[ip]
+	if !has {
+		ls = &LmpStts{}
+		s.Lmps[ip] = ls
+	}
+	ls.Draw, ls.Scl = draw, scl
+	if scl < 1 {
+		ls.Lmtd++
+	}
+}
+
+// FrmPwr - record estimated draw of every lamp together for a frame
+func (s *Stts) FrmPwr(draw float64, out float64, lmtd bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Pwr.Draw, s.Pwr.Out = draw, out
+	if draw > s.Pwr.MxDraw {
+		s.Pwr.MxDraw = draw
+	}
+	s.Pwr.LmpMA, s.Pwr.TtlMA = Pwr.LmpMA, Pwr.TtlMA
+	s.Pwr.Frms++
+	if lmtd {
+		s.Pwr.Lmtd++
+	}
+}
+
+// SetWrds - replace current words from wrdr
+func (s *Stts) SetWrds(w Wrdr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Wrds = make([]DataMsg, len(w.Wrds))
+	for i, wrd := range w.Wrds {
+		src, chc := w.DeDex(i)
+		s.Wrds[i] = DataMsg{
+			Source: src,
+			Flavor: "new_word",
+			Choice: chc,
+			Word:   wrd.Str,
+			Color:  []int{int(wrd.Clr[0]), int(wrd.Clr[1]), int(wrd.Clr[2])},
+		}
+	}
+}
+
+// SetPool - replace word pool entries
+func (s *Stts) SetPool(pws []PoolWrd) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Pool = pws
+}
+
+// GetPool - word pool entries
+func (s *Stts) GetPool() []PoolWrd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Pool
+}
+
+// SetRnd - record current round
+func (s *Stts) SetRnd(rs *RndStts) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Rnd = rs
+}
+
+// Touch - append touch message to vote feed
+func (s *Stts) Touch(dm DataMsg) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Feed = append(s.Feed, FdMsg{dm, NowMs()})
+	if len(s.Feed) > FeedSize {
+		s.Feed = s.Feed[len(s.Feed)-FeedSize:]
+	}
+}
+
+// SetMd - record current blnkr mode
+func (s *Stts) SetMd(md string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Md = md
+}
+
+// SetIdle - record if blnkr is in attract
+func (s *Stts) SetIdle(idle bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Idle = idle
+}
+
+// SetShw - record show program running
+func (s *Stts) SetShw(ss ShwStts) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Shw = ss
+}
+
+// SetScns - record current scene & the scenes there are, if given
+func (s *Stts) SetScns(nm string, nms []string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Scn = nm
+	if nms != nil {
+		s.Scns = nms
+	}
+}
+
+// SetRec - record name of running frame recording
+func (s *Stts) SetRec(fn string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Rec = fn
+}
+
+// JSON - marshal a consistent snapshot of status
+func (s *Stts) JSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(s)
+}
+
+// AdminHandlers - register dashboard, status & command handlers on default mux
+// commands are passed to the hub over ach
+func AdminHandlers(stts *Stts, ach chan string) {
+
+	// serve embedded dashboard assets
+	sub, err := fs.Sub(admnFS, "admin")
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/admin/", http.StripPrefix("/admin/", http.FileServer(http.FS(sub))))
+	http.Handle("/admin", http.RedirectHandler("/admin/", http.StatusMovedPermanently))
+
+	// current status snapshot as json
+	http.HandleFunc("/admin/status", func(w http.ResponseWriter, r *http.Request) {
+		b, err := stts.JSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+
+	// operator commands: POST /admin/cmd?do=test|blank|run|calibrate|reload_calibration|
+	// record|stop_record|cycle|next_round|prev_round|scene:<name>
+	http.HandleFunc("/admin/cmd", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		do := r.URL.Query().Get("do")
+		log.Printf("admin command '%v' from %v", do, r.RemoteAddr)
+
+		switch do {
+		case BlnkTest, BlnkBlank, BlnkRun, BlnkClbr, BlnkRldClbr, BlnkRecord, BlnkStopRecord,
+			"cycle", "next_round", "prev_round":
+		default:
+			if strings.HasPrefix(do, ScnCmd) && len(do) > len(ScnCmd) {
+				break
+			}
+			http.Error(w, "unknown command '"+do+"'", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case ach <- do:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			log.Printf("ERROR: admin command '%v' dropped, channel busy", do)
+			http.Error(w, "busy, try again", http.StatusServiceUnavailable)
+		}
+	})
+
+	log.Println("serving admin dashboard at http://localhost:8888/admin")
+}
+
+// write value as json response, or error as bad request
+func writeJSON(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("ERROR: failed to write json response: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// admin dashboard: polls /admin/status & posts commands to /admin/cmd
+var admn = {};
+
+// heartbeat & lamp send ages (ms) considered late / dead
+admn.late = 5000;
+admn.dead = 30000;
+
+// poll interval in ms
+admn.poll = 1000;
+
+admn.cmd = function (d) {
+  var xhr = new XMLHttpRequest();
+  xhr.open("POST", "cmd?do=" + d);
+  xhr.onload = function () {
+    var msg = xhr.status < 300 ? "sent " + d : "error: " + xhr.responseText;
+    document.getElementById("cmdmsg").textContent = msg;
+  };
+  xhr.send();
+};
+
+// reread word pool file on the server
+admn.pool = function () {
+  var xhr = new XMLHttpRequest();
+  xhr.open("POST", "pool");
+  xhr.onload = function () {
+    var msg = xhr.status < 300 ? "reloaded word pool" : "error: " + xhr.responseText;
+    document.getElementById("cmdmsg").textContent = msg;
+  };
+  xhr.send();
+};
+
+// css class for age of last event
+admn.age = function (now, t) {
+  if (!t) {
+    return "dead";
+  }
+  var a = now - t;
+  if (a > admn.dead) {
+    return "dead";
+  }
+  if (a > admn.late) {
+    return "late";
+  }
+  return "ok";
+};
+
+// convert 12 bit color array to css color
+admn.css = function (c) {
+  if (!c) {
+    return "#000";
+  }
+  var h = function (v) { return Math.round(v / 0xfff * 255); };
+  return "rgb(" + h(c[0]) + "," + h(c[1]) + "," + h(c[2]) + ")";
+};
+
+// color swatch element to put before a word in a cell
+admn.swatch = function (c) {
+  var s = document.createElement("span");
+  s.className = "swatch";
+  s.style.background = admn.css(c);
+  return s;
+};
+
+admn.secs = function (now, t) {
+  return t ? ((now - t) / 1000).toFixed(1) + "s ago" : "never";
+};
+
+// append cell value to el as text, a value can be an element or a list of values
+admn.fill = function (el, v) {
+  if (Array.isArray(v)) {
+    v.forEach(function (p) { admn.fill(el, p); });
+  } else if (v instanceof Node) {
+    el.appendChild(v);
+  } else if (v !== undefined && v !== null) {
+    el.appendChild(document.createTextNode(String(v)));
+  }
+};
+
+// replace table contents with header & rows of cells, never parsing server strings as html
+admn.rows = function (id, hdr, rows) {
+  var tbl = document.getElementById(id);
+  tbl.textContent = "";
+  var tr = document.createElement("tr");
+  hdr.forEach(function (c) {
+    var th = document.createElement("th");
+    th.textContent = c;
+    tr.appendChild(th);
+  });
+  tbl.appendChild(tr);
+  rows.forEach(function (r) {
+    var tr = document.createElement("tr");
+    tr.className = r.cls || "";
+    r.cells.forEach(function (c) {
+      var td = document.createElement("td");
+      admn.fill(td, c);
+      tr.appendChild(td);
+    });
+    tbl.appendChild(tr);
+  });
+};
+
+// override show schedule with program, or clear override if empty
+admn.shw = function (prg) {
+  var xhr = new XMLHttpRequest();
+  xhr.open(prg ? "POST" : "DELETE", "schedule");
+  xhr.onload = function () {
+    var msg = xhr.status < 300 ? (prg ? "running " + prg : "back to schedule") : "error: " + xhr.responseText;
+    document.getElementById("cmdmsg").textContent = msg;
+  };
+  xhr.send(prg ? JSON.stringify({ program: prg }) : null);
+};
+
+admn.render = function (s) {
+  var now = Date.now();
+  document.getElementById("mode").textContent = s.mode + (s.idle ? " (attract)" : "");
+  document.getElementById("recording").textContent = s.recording ? "recording " + s.recording : "";
+
+  var p = s.show;
+  document.getElementById("program").textContent = "program " + p.program +
+    " at " + (p.brightness * 100).toFixed(0) + "%" +
+    (p.override ? " (override)" : p.entry >= 0 ? " (schedule entry " + (p.entry + 1) + ")" : " (default)");
+
+  var sc = document.getElementById("scenes");
+  var scs = (s.scenes || []).join(",");
+  if (sc.dataset.names !== scs) { // only rebuild buttons when scenes change
+    sc.dataset.names = scs;
+    sc.innerHTML = "";
+    (s.scenes || []).forEach(function (nm) {
+      var b = document.createElement("button");
+      b.textContent = "Scene: " + nm;
+      b.onclick = function () { admn.cmd("scene:" + encodeURIComponent(nm)); };
+      sc.appendChild(b);
+    });
+    var cur = document.createElement("span");
+    cur.id = "scene";
+    sc.appendChild(cur);
+  }
+  document.getElementById("scene").textContent = "scene " + s.scene;
+
+  var r = s.round;
+  document.getElementById("round").textContent = r ?
+    "round " + (r.index + 1) + " of " + r.count + ": " + r.question +
+    (r.end ? " (" + Math.max(0, (r.end - now) / 1000).toFixed(0) + "s left)" : "") : "no rounds";
+
+  admn.rows("stations", ["source", "last beat"], Object.keys(s.beats).map(function (k) {
+    return { cls: admn.age(now, s.beats[k]), cells: [k, admn.secs(now, s.beats[k])] };
+  }));
+
+  admn.rows("words", ["source", "choice", "word"], (s.words || []).map(function (w) {
+    return { cells: [w.source, w.choice, [admn.swatch(w.color), " " + w.word]] };
+  }));
+
+  var f = s.frame;
+  admn.rows("frame", ["", ""], [
+    { cells: ["frames", f.count] },
+    { cells: ["interval", f.interval.toFixed(1) + " ms"] },
+    { cells: ["render", f.render.toFixed(2) + " ms"] },
+    { cells: ["max render", f.max_render.toFixed(2) + " ms"] },
+    { cells: ["uptime", ((now - s.start) / 60000).toFixed(1) + " min"] }
+  ]);
+
+  var p = s.power;
+  admn.rows("power", ["", ""], [
+    { cells: ["draw", p.draw.toFixed(0) + " mA"] },
+    { cells: ["sent", p.out.toFixed(0) + " mA"] },
+    { cells: ["max draw", p.max_draw.toFixed(0) + " mA"] },
+    { cells: ["budget", (p.total_ma || "-") + " total, " + (p.lamp_ma || "-") + " per lamp"] },
+    { cells: ["limited", p.limited + " of " + p.frames + " frames"] }
+  ]);
+
+  admn.rows("lamps", ["ip", "last send", "sent", "failed", "error", "draw", "scale", "limited"], Object.keys(s.lamps).sort().map(function (k) {
+    var l = s.lamps[k];
+    return { cls: admn.age(now, l.last_send), cells: [k, admn.secs(now, l.last_send), l.sent, l.failed, l.last_error, l.draw.toFixed(0) + " mA", l.scale.toFixed(2), l.limited] };
+  }));
+
+  admn.rows("feed", ["time", "source", "choice", "flavor", "word"], (s.feed || []).slice().reverse().map(function (m) {
+    return { cells: [new Date(m.time).toLocaleTimeString(), m.source, m.choice, m.flavor, [admn.swatch(m.color), " " + m.word]] };
+  }));
+};
+
+admn.update = function () {
+  var xhr = new XMLHttpRequest();
+  xhr.open("GET", "status");
+  xhr.onload = function () {
+    if (xhr.status === 200) {
+      admn.render(JSON.parse(xhr.responseText));
+    }
+    setTimeout(admn.update, admn.poll);
+  };
+  xhr.onerror = function () {
+    document.getElementById("mode").textContent = "disconnected";
+    setTimeout(admn.update, admn.poll);
+  };
+  xhr.send();
+};
+
+window.onload = admn.update;
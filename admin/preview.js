@@ -0,0 +1,121 @@
+// 3d preview: draws every led from /preview/layout at its coords & colors
+// them from binary frames streamed over the /frames websocket
+var prvw = {};
+
+prvw.yaw = 0.6;    // orbit angle around vertical axis
+prvw.pitch = 0.5;  // orbit angle above horizontal
+prvw.dist = 600;   // camera distance from layout center
+prvw.focal = 700;  // perspective focal length in px
+prvw.leds = [];
+prvw.clrs = [];
+
+prvw.reset = function () {
+  prvw.yaw = 0.6;
+  prvw.pitch = 0.5;
+  prvw.dist = 600;
+  prvw.draw();
+};
+
+// rotate & project led coords to canvas, nearest leds drawn last
+prvw.draw = function () {
+  var cnv = document.getElementById("view");
+  var ctx = cnv.getContext("2d");
+  ctx.fillStyle = "#000";
+  ctx.fillRect(0, 0, cnv.width, cnv.height);
+
+  var cy = Math.cos(prvw.yaw), sy = Math.sin(prvw.yaw);
+  var cp = Math.cos(prvw.pitch), sp = Math.sin(prvw.pitch);
+  var pts = [];
+  prvw.leds.forEach(function (l, i) {
+    var x = l.x - prvw.cx, y = l.y - prvw.cy, z = l.z - prvw.cz;
+    var x1 = x * cy - z * sy;
+    var z1 = x * sy + z * cy;
+    var y1 = y * cp - z1 * sp;
+    var z2 = y * sp + z1 * cp + prvw.dist;
+    if (z2 <= 1) {
+      return;
+    }
+    var s = prvw.focal / z2;
+    pts.push({ x: cnv.width / 2 + x1 * s, y: cnv.height / 2 - y1 * s, z: z2, s: s, i: i });
+  });
+  pts.sort(function (a, b) { return b.z - a.z; });
+
+  pts.forEach(function (p) {
+    var c = prvw.clrs[p.i] || [0, 0, 0];
+    var r = Math.max(2, 6 * p.s);
+    ctx.beginPath();
+    ctx.arc(p.x, p.y, r, 0, 2 * Math.PI);
+    ctx.fillStyle = "rgb(" + c[0] + "," + c[1] + "," + c[2] + ")";
+    ctx.fill();
+    ctx.strokeStyle = "#333";
+    ctx.stroke();
+  });
+};
+
+// apply binary frame: uint32 frame #, then r g b bytes per led
+prvw.frame = function (buf) {
+  var b = new Uint8Array(buf);
+  for (var i = 0; i < prvw.leds.length; i++) {
+    var o = 4 + 3 * i;
+    prvw.clrs[i] = [b[o], b[o + 1], b[o + 2]];
+  }
+  var n = new DataView(buf).getUint32(0);
+  document.getElementById("status").textContent = "frame " + n;
+  prvw.draw();
+};
+
+prvw.connect = function () {
+  var ws = new WebSocket("ws://" + location.host + "/frames");
+  ws.binaryType = "arraybuffer";
+  ws.onmessage = function (e) { prvw.frame(e.data); };
+  ws.onclose = function () {
+    document.getElementById("status").textContent = "disconnected";
+    setTimeout(prvw.connect, 2000);
+  };
+};
+
+prvw.controls = function () {
+  var cnv = document.getElementById("view");
+  var drag = null;
+  cnv.onmousedown = function (e) { drag = { x: e.clientX, y: e.clientY }; };
+  window.onmouseup = function () { drag = null; };
+  window.onmousemove = function (e) {
+    if (!drag) {
+      return;
+    }
+    prvw.yaw += (e.clientX - drag.x) * 0.01;
+    prvw.pitch = Math.max(-1.5, Math.min(1.5, prvw.pitch + (e.clientY - drag.y) * 0.01));
+    drag = { x: e.clientX, y: e.clientY };
+    prvw.draw();
+  };
+  cnv.onwheel = function (e) {
+    e.preventDefault();
+    prvw.dist = Math.max(50, prvw.dist * (e.deltaY > 0 ? 1.1 : 0.9));
+    prvw.draw();
+  };
+  cnv.ondblclick = prvw.reset;
+};
+
+window.onload = function () {
+  prvw.controls();
+  var xhr = new XMLHttpRequest();
+  xhr.open("GET", "/preview/layout");
+  xhr.onload = function () {
+    prvw.leds = JSON.parse(xhr.responseText);
+
+    // orbit around center of layout bounds
+    var mn = [Infinity, Infinity, Infinity], mx = [-Infinity, -Infinity, -Infinity];
+    prvw.leds.forEach(function (l) {
+      [l.x, l.y, l.z].forEach(function (v, k) {
+        mn[k] = Math.min(mn[k], v);
+        mx[k] = Math.max(mx[k], v);
+      });
+    });
+    prvw.cx = (mn[0] + mx[0]) / 2;
+    prvw.cy = (mn[1] + mx[1]) / 2;
+    prvw.cz = (mn[2] + mx[2]) / 2;
+    prvw.draw();
+    prvw.connect();
+  };
+  xhr.send();
+};
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Tch - a paired start & end touch on one side of a vote station
+type Tch struct {
+	Start  int64  `json:"start"`    // unix ms of start_touch
+	Dur    int64  `json:"duration"` // ms
+	Word   string `json:"word"`
+	Source int    `json:"source"`
+	Choice string `json:"choice"`
+}
+
+// TchCfg - thresholds for pairing touches
+type TchCfg struct {
+	MinDur int64 // end touches sooner than this after start are stutter & ignored
+	MaxDur int64 // touches this long or longer are dropped as stuck or leaned on
+}
+
+// TchCnts - counts of log records that didnt become touches
+type TchCnts struct {
+	Stutters int // end touches ignored as stutter
+	TooLong  int // touches dropped for exceeding max duration
+	Orphans  int // end touches without an open start
+	Open     int // start touches never ended
+	BadLines int // lines that couldnt be parsed
+}
+
+// slot key for pairing touches per station side
+type tchKey struct {
+	src int
+	chc string
+}
+
+// ReadWrdLgs - read json lines word log, skipping & counting lines that dont parse
+func ReadWrdLgs(r io.Reader) ([]WrdLg, int, error) {
+	lgs := []WrdLg{}
+	bad := 0
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var lg WrdLg
+		if err := json.Unmarshal(sc.Bytes(), &lg); err != nil {
+			log.Printf("cant parse line %v '%v': %v", ln, sc.Text(), err)
+			bad++
+			continue
+		}
+		lgs = append(lgs, lg)
+	}
+	return lgs, bad, sc.Err()
+}
+
+// ReadWrdLgFiles - read word logs from comma separated files or globs, like
+// 'wordlog*.json' for a log & its rotated files, merged in time order; the
+// session start repeated at the top of every rotated file is kept once
+func ReadWrdLgFiles(in string) ([]WrdLg, int, []string, error) {
+	fns := []string{}
+	seen := make(map[string]bool) // files matched by more than one pattern
+	for _, pat := range strings.Split(in, ",") {
+		pat = strings.TrimSpace(pat)
+		if pat == "" {
+			continue
+		}
+		mtchs, err := filepath.Glob(pat)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if len(mtchs) == 0 {
+			return nil, 0, nil, fmt.Errorf("no word log matches %v", pat)
+		}
+		for _, fn := range mtchs {
+			if !seen[fn] {
+				seen[fn] = true
+				fns = append(fns, fn)
+			}
+		}
+	}
+	if len(fns) == 0 {
+		return nil, 0, nil, errors.New("no word logs given")
+	}
+
+	lgs := []WrdLg{}
+	bad := 0
+	type sssn struct {
+		t   int64
+		tag string
+	}
+	sssns := make(map[sssn]bool)
+	for _, fn := range fns {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		flgs, fbad, err := ReadWrdLgs(f)
+		f.Close()
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("%v: %v", fn, err)
+		}
+		bad += fbad
+		for _, lg := range flgs {
+			if lg.Flavor == "session_start" {
+				k := sssn{lg.Time, lg.Session}
+				if sssns[k] {
+					continue
+				}
+				sssns[k] = true
+			}
+			lgs = append(lgs, lg)
+		}
+	}
+	sort.SliceStable(lgs, func(i, j int) bool { return lgs[i].Time < lgs[j].Time })
+	return lgs, bad, fns, nil
+}
+
+// PairTchs - pair start & end touches per (source, choice) in log order
+func PairTchs(lgs []WrdLg, cfg TchCfg) ([]Tch, TchCnts) {
+	tchs := []Tch{}
+	cnts := TchCnts{}
+	open := make(map[tchKey]WrdLg)
+
+	for _, lg := range lgs {
+		k := tchKey{lg.Source, lg.Choice}
+		switch lg.Flavor {
+
+		case "start_touch":
+			if _, has := open[k]; !has { // repeated starts are stutter, keep first
+				open[k] = lg
+			}
+
+		case "end_touch":
+			strt, has := open[k]
+			if !has {
+				cnts.Orphans++
+				continue
+			}
+			dur := lg.Time - strt.Time
+			if dur <= cfg.MinDur { // keep touch open & wait for a later end
+				cnts.Stutters++
+				continue
+			}
+			delete(open, k)
+			if cfg.MaxDur > 0 && dur >= cfg.MaxDur {
+				cnts.TooLong++
+				continue
+			}
+			tchs = append(tchs, Tch{
+				Start:  strt.Time,
+				Dur:    dur,
+				Word:   strt.Word,
+				Source: strt.Source,
+				Choice: strt.Choice,
+			})
+		}
+	}
+	cnts.Open = len(open)
+
+	sort.SliceStable(tchs, func(i, j int) bool { return tchs[i].Start < tchs[j].Start })
+	return tchs, cnts
+}
+
+// WriteTchCSV - write touches as csv rows of time, duration, word, source, choice
+func WriteTchCSV(w io.Writer, tchs []Tch) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "duration", "word", "source", "choice"})
+	for _, t := range tchs {
+		cw.Write([]string{
+			time.Unix(0, t.Start*1000000).Format(time.RFC3339),
+			strconv.FormatInt(t.Dur, 10),
+			t.Word,
+			strconv.Itoa(t.Source),
+			t.Choice,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// TchSmry - touch count & durations for a word or station
+type TchSmry struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Total int64  `json:"total_ms"`
+	Mean  int64  `json:"mean_ms"`
+}
+
+// SmryBy - summarize touches grouped by key, most touched first
+func SmryBy(tchs []Tch, key func(Tch) string) []TchSmry {
+	idx := make(map[string]*TchSmry)
+	for _, t := range tchs {
+		k := key(t)
+		s, has := idx[k]
+		if !has {
+			s = &TchSmry{Key: k}
+			idx[k] = s
+		}
+		s.Count++
+		s.Total += t.Dur
+	}
+	smrs := []TchSmry{}
+	for _, s := range idx {
+		s.Mean = s.Total / int64(s.Count)
+		smrs = append(smrs, *s)
+	}
+	sort.Slice(smrs, func(i, j int) bool {
+		if smrs[i].Count != smrs[j].Count {
+			return smrs[i].Count > smrs[j].Count
+		}
+		return smrs[i].Key < smrs[j].Key
+	})
+	return smrs
+}
+
+// write summary table of smrs with heading
+func writeSmry(w io.Writer, hdg string, smrs []TchSmry) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%v\ttouches\ttotal s\tmean ms\n", hdg)
+	for _, s := range smrs {
+		fmt.Fprintf(tw, "%v\t%v\t%.1f\t%v\n", s.Key, s.Count, float64(s.Total)/1000, s.Mean)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// AnalyzeCmd - pair touches from word log & write csv, json & summary table
+func AnalyzeCmd(args []string) error {
+	fset := flag.NewFlagSet("analyze", flag.ExitOnError)
+	in := fset.String("in", "wordlog.json", "word logs to analyze, comma separated files or globs like 'wordlog*.json'")
+	csvfn := fset.String("csv", "wordlog.csv", "csv file of touches, empty to skip")
+	jsonfn := fset.String("json", "", "json file of touches & summaries, empty to skip")
+	mindur := fset.Duration("min", time.Duration(StttrMs)*time.Millisecond, "ignore end touches sooner than this as stutter")
+	maxdur := fset.Duration("max", 20*time.Second, "drop touches this long or longer, 0 keeps all")
+	fset.Parse(args)
+
+	lgs, bad, fns, err := ReadWrdLgFiles(*in)
+	if err != nil {
+		return err
+	}
+
+	tchs, cnts := PairTchs(lgs, TchCfg{
+		MinDur: mindur.Nanoseconds() / 1000000,
+		MaxDur: maxdur.Nanoseconds() / 1000000,
+	})
+	cnts.BadLines = bad
+	wrds := SmryBy(tchs, func(t Tch) string { return t.Word })
+	stns := SmryBy(tchs, func(t Tch) string { return fmt.Sprintf("%v %v", t.Source, t.Choice) })
+
+	if *csvfn != "" {
+		cf, err := os.Create(*csvfn)
+		if err != nil {
+			return err
+		}
+		if err := WriteTchCSV(cf, tchs); err != nil {
+			cf.Close()
+			return err
+		}
+		if err := cf.Close(); err != nil {
+			return err
+		}
+	}
+
+	if *jsonfn != "" {
+		jf, err := os.Create(*jsonfn)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(jf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Touches  []Tch     `json:"touches"`
+			Words    []TchSmry `json:"words"`
+			Stations []TchSmry `json:"stations"`
+			Skipped  TchCnts   `json:"skipped"`
+		}{tchs, wrds, stns, cnts})
+		if err != nil {
+			jf.Close()
+			return err
+		}
+		if err := jf.Close(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%v touches from %v records in %v\n", len(tchs), len(lgs), strings.Join(fns, ", "))
+	fmt.Printf("skipped: %v stutter ends, %v too long, %v orphan ends, %v never ended, %v bad lines\n\n",
+		cnts.Stutters, cnts.TooLong, cnts.Orphans, cnts.Open, cnts.BadLines)
+	writeSmry(os.Stdout, "word", wrds)
+	writeSmry(os.Stdout, "station", stns)
+	return nil
+}
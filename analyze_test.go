@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadWrdLgFiles(t *testing.T) {
+	dir := t.TempDir()
+	hdr := `{"flavor":"session_start","time":1000,"session":"a","stations":[101]}` + "\n"
+	fls := map[string]string{
+		"wordlog-20261019-010000.json": hdr +
+			`{"word":"Happy","flavor":"start_touch","source":101,"choice":"left","time":2000}` + "\n",
+		"wordlog.json": hdr +
+			`{"word":"Happy","flavor":"end_touch","source":101,"choice":"left","time":2600}` + "\n" +
+			`{"flavor":"session_start","time":5000,"session":"b"}` + "\n" +
+			"not json\n",
+	}
+	for fn, s := range fls {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	glb := filepath.Join(dir, "wordlog*.json")
+	tests := []struct {
+		name  string
+		in    string
+		files int
+		lgs   int
+		err   bool
+	}{
+		{"glob", glb, 2, 4, false},
+		{"list", filepath.Join(dir, "wordlog.json") + ", " + filepath.Join(dir, "wordlog-20261019-010000.json"), 2, 4, false},
+		{"overlapping", glb + "," + filepath.Join(dir, "wordlog.json"), 2, 4, false},
+		{"one file", filepath.Join(dir, "wordlog.json"), 1, 3, false},
+		{"no match", filepath.Join(dir, "nope*.json"), 0, 0, true},
+		{"empty", "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		lgs, bad, fns, err := ReadWrdLgFiles(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if len(fns) != tt.files || len(lgs) != tt.lgs || bad != 1 {
+			t.Errorf("%v: got %v files, %v records, %v bad, want %v, %v, 1", tt.name, len(fns), len(lgs), bad, tt.files, tt.lgs)
+		}
+		for i := 1; i < len(lgs); i++ {
+			if lgs[i].Time < lgs[i-1].Time {
+				t.Errorf("%v: records out of time order", tt.name)
+			}
+		}
+	}
+}
+
+// log exercising stutter, orphan, overlong & unfinished touches, with the
+// touches parse_wordlog.py wrote for it before analyze replaced it
+const pyWrdLg = `{"word":"Happy","flavor":"start_touch","source":101,"choice":"left","time":1000}
+{"word":"Happy","flavor":"end_touch","source":101,"choice":"left","time":1150}
+{"word":"Happy","flavor":"end_touch","source":101,"choice":"left","time":1900}
+{"word":"Calm","flavor":"start_touch","source":102,"choice":"right","time":3000}
+{"word":"Calm","flavor":"start_touch","source":102,"choice":"right","time":3100}
+{"word":"Calm","flavor":"end_touch","source":102,"choice":"right","time":3200}
+{"word":"Calm","flavor":"end_touch","source":102,"choice":"right","time":4500}
+{"word":"Bold","flavor":"end_touch","source":103,"choice":"left","time":5000}
+{"word":"Bold","flavor":"start_touch","source":103,"choice":"left","time":6000}
+{"word":"Bold","flavor":"end_touch","source":103,"choice":"left","time":27000}
+{"word":"Bold","flavor":"start_touch","source":103,"choice":"left","time":28000}
+{"word":"Bold","flavor":"end_touch","source":103,"choice":"left","time":28201}
+{"word":"Wild","flavor":"start_touch","source":101,"choice":"right","time":30000}
+{"word":"Wild","flavor":"end_touch","source":101,"choice":"right","time":30200}
+{"word":"Wild","flavor":"end_touch","source":101,"choice":"right","time":50000}
+{"word":"Quiet","flavor":"start_touch","source":102,"choice":"left","time":60000}
+{"word":"Quiet","flavor":"end_touch","source":102,"choice":"left","time":79999}
+{"word":"Loud","flavor":"start_touch","source":103,"choice":"right","time":80000}
+`
+
+func TestPairTchs(t *testing.T) {
+	lgs, bad, err := ReadWrdLgs(strings.NewReader(pyWrdLg))
+	if err != nil || bad != 0 {
+		t.Fatalf("read log: %v, %v bad lines", err, bad)
+	}
+	tchs, cnts := PairTchs(lgs, TchCfg{MinDur: 200, MaxDur: 20000})
+
+	// time,duration,word,source,choice rows of parse_wordlog.py
+	want := []Tch{
+		{1000, 900, "Happy", 101, "left"},
+		{3000, 1500, "Calm", 102, "right"},
+		{28000, 201, "Bold", 103, "left"},
+		{60000, 19999, "Quiet", 102, "left"},
+	}
+	if len(tchs) != len(want) {
+		t.Fatalf("got %v touches, want %v: %v", len(tchs), len(want), tchs)
+	}
+	for i := range want {
+		if tchs[i] != want[i] {
+			t.Errorf("touch %v: got %v, want %v", i, tchs[i], want[i])
+		}
+	}
+	if wc := (TchCnts{Stutters: 3, TooLong: 2, Orphans: 1, Open: 1}); cnts != wc {
+		t.Errorf("got counts %+v, want %+v", cnts, wc)
+	}
+}
+
+func TestPairTchsThresholds(t *testing.T) {
+	lg := func(flvr string, tm int64) WrdLg {
+		return WrdLg{Word: "Happy", Flavor: flvr, Source: 101, Choice: "left", Time: tm}
+	}
+	tests := []struct {
+		name string
+		cfg  TchCfg
+		lgs  []WrdLg
+		durs []int64
+		cnts TchCnts
+	}{
+		{"end at min is stutter", TchCfg{MinDur: 200},
+			[]WrdLg{lg("start_touch", 0), lg("end_touch", 200)}, nil, TchCnts{Stutters: 1, Open: 1}},
+		{"end past min pairs", TchCfg{MinDur: 200},
+			[]WrdLg{lg("start_touch", 0), lg("end_touch", 201)}, []int64{201}, TchCnts{}},
+		{"no max keeps long", TchCfg{MinDur: 200},
+			[]WrdLg{lg("start_touch", 0), lg("end_touch", 90000)}, []int64{90000}, TchCnts{}},
+		{"end at max dropped", TchCfg{MaxDur: 1000},
+			[]WrdLg{lg("start_touch", 0), lg("end_touch", 1000)}, nil, TchCnts{TooLong: 1}},
+		{"repeated start keeps first", TchCfg{},
+			[]WrdLg{lg("start_touch", 0), lg("start_touch", 50), lg("end_touch", 300)}, []int64{300}, TchCnts{}},
+		{"sides pair apart", TchCfg{},
+			[]WrdLg{lg("start_touch", 0), {Flavor: "end_touch", Source: 101, Choice: "right", Time: 300}},
+			nil, TchCnts{Orphans: 1, Open: 1}},
+	}
+	for _, tt := range tests {
+		tchs, cnts := PairTchs(tt.lgs, tt.cfg)
+		durs := []int64{}
+		for _, tch := range tchs {
+			durs = append(durs, tch.Dur)
+		}
+		if len(durs) != len(tt.durs) || cnts != tt.cnts {
+			t.Errorf("%v: got %v & %+v, want %v & %+v", tt.name, durs, cnts, tt.durs, tt.cnts)
+			continue
+		}
+		for i := range durs {
+			if durs[i] != tt.durs[i] {
+				t.Errorf("%v: got %v, want %v", tt.name, durs, tt.durs)
+			}
+		}
+	}
+}
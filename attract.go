@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+)
+
+// IdleDelay - ms without touches before blnkr fades to attract, 0 never
+var IdleDelay = int64(300000)
+
+// attract timing in milliseconds
+const (
+	AttrFdIn  = 4000  // crossfade from waves to attract
+	AttrFdOut = 1000  // crossfade back once somebody touches a station
+	AttrStep  = 10000 // time to blend from one word color to the next
+	AttrRppl  = 5000  // time for a ripple to close in on its station
+)
+
+// AttrGlow - brightness of the attract color over the whole wall
+const AttrGlow = 0.08
+
+// ScnAttr - scene shown as attract instead of the built in program if the
+// scenes file has it
+const ScnAttr = "attract"
+
+// Touch - note station activity, fades back from attract
+func (blnkr *Blnkr) Touch() {
+	blnkr.LstTch = NowMs()
+}
+
+// attract - fade toward attract program while no one touches a station or
+// the show program is idle & back on the first touch; attract slowly cycles
+// the current word colors & draws ripples closing in on each station, or
+// shows the attract scene if there is one
+func (blnkr *Blnkr) attract() {
+	now := NowMs()
+	if blnkr.LstTch == 0 {
+		blnkr.LstTch = now // idle from when blnkr started
+	}
+	idle := blnkr.Prg == PrgIdle || (IdleDelay > 0 && now-blnkr.LstTch >= IdleDelay)
+	if idle != blnkr.Idle {
+		if idle {
+			log.Printf("idle, fading to attract")
+		} else {
+			log.Printf("touch, fading back from attract")
+		}
+		blnkr.Idle = idle
+		blnkr.Stts.SetIdle(idle)
+	}
+
+	if idle {
+		blnkr.Attr += float64(UpdateDelay) / AttrFdIn
+	} else {
+		blnkr.Attr -= float64(UpdateDelay) / AttrFdOut
+	}
+	if blnkr.Attr > 1 {
+		blnkr.Attr = 1
+	}
+	if blnkr.Attr <= 0 {
+		blnkr.Attr = 0
+		return
+	}
+
+	if sc := blnkr.Scns[ScnAttr]; sc != nil {
+		blnkr.attractScn(sc)
+		return
+	}
+
+	// blend through word colors, or default wave color without words
+	clr := WvClr
+	if n := int64(len(blnkr.Wrds)); n > 0 {
+		i := now / AttrStep
+		frac := float64(now%AttrStep) / AttrStep
+		clr = blnkr.Wrds[i%n].Clr.Lerp(blnkr.Wrds[(i+1)%n].Clr, frac)
+	}
+	glow := clr.ToF().Dim(AttrGlow)
+
+	// one ripple per station, staggered so they dont all land together
+	rppls := []Wv{}
+	for edx := 1; edx < len(blnkr.Epcntrs); edx++ {
+		phs := float64((now+int64(edx)*AttrRppl/3)%AttrRppl) / AttrRppl
+		rppls = append(rppls, Wv{Mn: blnkr.Mxrs[edx] * (1 - phs), SD: 1.0, Xs: 4.0, Ys: 1.0, Clr: clr})
+	}
+
+	for _, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			pnt := &lmp.Pnts[i]
+			f := glow
+			for r, wv := range rppls {
+				if len(pnt.Mres) > r+1 {
+					f = f.Add(wv.ColorAt(pnt.Mres[r+1]))
+				}
+			}
+			for c := 0; c < 3; c++ {
+				pnt.F[c] += (f[c] - pnt.F[c]) * blnkr.Attr
+			}
+		}
+	}
+}
+
+// crossfade to attract scene rendered over what blnkr shows
+func (blnkr *Blnkr) attractScn(sc *Scene) {
+	cur := make(map[string][LampSize]FRGB)
+	for ip, lmp := range blnkr.Lmps {
+		cur[ip] = lmp.Flts()
+	}
+	blnkr.composite(sc)
+	for ip, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			pnt := &lmp.Pnts[i]
+			for c := 0; c < 3; c++ {
+				pnt.F[c] = cur[ip][i][c] + (pnt.F[c]-cur[ip][i][c])*blnkr.Attr
+			}
+		}
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"log"
 	"math"
 	"net"
+	"sort"
+	"time"
 
 	"github.com/go-gl/mathgl/mgl64"
 )
@@ -29,6 +32,32 @@ var StrkThrsh = int(7)
 // WvClr - default inwv color
 var WvClr = RGB{0x777, 0x777, 0x777}
 
+// blnkr modes, set from the admin dashboard
+const (
+	BlnkRun   = "run"       // render waves
+	BlnkTest  = "test"      // step every lamp through reference colors
+	BlnkBlank = "blank"     // all leds off
+	BlnkClbr  = "calibrate" // hold calibration reference colors on every led
+)
+
+// blnkr recording commands, set from the admin dashboard
+const (
+	BlnkRecord     = "record"
+	BlnkStopRecord = "stop_record"
+	BlnkRldClbr    = "reload_calibration"
+)
+
+// TestStep - # of frames each test pattern color is shown
+const TestStep = 30
+
+// TestClrs - reference colors for lamp test pattern
+var TestClrs = []RGB{
+	{0xfff, 0x000, 0x000},
+	{0x000, 0xfff, 0x000},
+	{0x000, 0x000, 0xfff},
+	{0xfff, 0xfff, 0xfff},
+}
+
 // Led - type for reading led data from json file
 type Led struct {
 	IP    string  `json:"ip"`
@@ -70,7 +99,11 @@ func (rgb RGB) Dim(f float64) RGB {
 type Pnt struct {
 	Crds mgl64.Vec3
 	Mres []float64 // min radius to epicenter
+	F    FRGB      // float render buffer, rounded into Clr once per frame
 	Clr  RGB
+	Err  [3]float64 // dither error of output carried to next frame
+	Lst  FRGB       // last frame before brightness & limiting, transitions start from it
+	Prt  FRGB       // particle light this frame
 }
 
 // VtClr - station & color of vote
@@ -83,6 +116,8 @@ type VtClr struct {
 type Lmp struct {
 	IP   string
 	Pnts [LampSize]Pnt
+	Cal  [LampSize]FRGB // calibrated float colors, limited & quantized into Out
+	Out  [LampSize]RGB  // calibrated colors at output bit depth as sent
 }
 
 // Wv - wave pattern to render in lmp topos
@@ -100,8 +135,40 @@ type Blnkr struct {
 	Lmps    map[string]*Lmp
 	Wvs     [][]Wv
 	Epcntrs [][]mgl64.Vec3
-	StnMp   map[int]int // map from vote station source to epicenter index
-	Mxrs    []float64   // max radius from epicenter
+	StnMp   map[int]int        // map from vote station source to epicenter index
+	Mxrs    []float64          // max radius from epicenter
+	Md      string             // current mode
+	LstClr  RGB                // color of last vote
+	ClrStrk int                // # of consecutive votes in last color
+	Frm     int64              // # of frames rendered
+	Strt    int64              // time of frame 0 in ms, frame n renders at Strt + (n+1)*UpdateDelay
+	Stts    *Stts              // optional status for admin dashboard
+	Leds    []Led              // leds sorted by ip & index, order of preview frames
+	IPs     []string           // sorted lmp ips, order of recorded frames
+	Rcrdr   *FrmWrtr           // optional recording of udpcast frames
+	Frmch   chan []byte        // optional channel to stream preview frames
+	Thm     *Theme             // lighting theme of current round, nil for defaults
+	Clbr    *Clbr              // optional calibration of output colors
+	ClbrFn  string             // file calibration is (re)loaded from
+	Scls    map[string]float64 // power limiter brightness scale per lamp
+	Lyrs    []Lyr              // wave layers composited in order, bottom first
+	Wrds    []Wrd              // current station words, colors for attract
+	LstTch  int64              // time of last station touch in ms
+	Idle    bool               // no touches for IdleDelay
+	Attr    float64            // crossfade from waves to attract, 0-1
+	Shw     *ShwSchd           // optional time of day schedule of programs
+	ShwStts ShwStts            // schedule entry running
+	Prg     string             // show program running
+	ShwMd   string             // mode the show program wants
+	OprMd   string             // mode an operator set over the show program, empty to follow it
+	Brt     float64            // brightness, fading toward program brightness
+	Seq     []*RecFrm          // frames of sequence program
+	SeqStrt int64              // time sequence started in ms
+	Scns    map[string]*Scene  // scenes by name
+	Scn     *Scene             // current scene, nil for blnkr layers
+	Trn     *Trnstn            // running transition
+	PCfg    *PrtclCfg          // optional particles emitted by votes
+	Prtcls  []*Prtcl           // particles in flight
 }
 
 // NewBlnkr - init blnkr with given json file
@@ -124,6 +191,9 @@ func NewBlnkr(jsond []byte) (*Blnkr, error) {
 			[]mgl64.Vec3{{260.0, 0.0, 0.0}},
 		},
 		StnMp: map[int]int{101: 1, 102: 2, 103: 3},
+		Md:    BlnkRun,
+		Lyrs:  DfltLyrs,
+		Brt:   1,
 	}
 	mxrs := []float64{0, 0, 0, 0} // get max (min) radius of leds from epicenters
 
@@ -151,6 +221,20 @@ func NewBlnkr(jsond []byte) (*Blnkr, error) {
 	}
 	blnkr.Lmps = lmps
 	blnkr.Mxrs = mxrs
+
+	// keep leds in a stable order for preview frames
+	sort.Slice(leds, func(i, j int) bool {
+		if leds[i].IP != leds[j].IP {
+			return leds[i].IP < leds[j].IP
+		}
+		return leds[i].Index < leds[j].Index
+	})
+	blnkr.Leds = leds
+	for _, led := range leds {
+		if len(blnkr.IPs) == 0 || blnkr.IPs[len(blnkr.IPs)-1] != led.IP {
+			blnkr.IPs = append(blnkr.IPs, led.IP)
+		}
+	}
 	blnkr.Wvs = make([][]Wv, 4)
 
 	return &blnkr, nil
@@ -171,79 +255,193 @@ func (blnkr *Blnkr) mre(dx int, crds mgl64.Vec3) float64 {
 // UDPCast - send udp packet with current colors to each lamp
 func (blnkr *Blnkr) UDPCast() {
 	for ip, lmp := range blnkr.Lmps {
+		err := SendPckt(ip+":"+UDPPort, LmpPckt(blnkr.Out(lmp), OutBits))
+		blnkr.Stts.LmpSnt(ip, err)
+	}
+	blnkr.record()
+}
 
-		// write color values for lamps leds to buffer
-		buf := new(bytes.Buffer)
-		for i := 0; i < LampSize; i++ {
-			err := binary.Write(buf, binary.BigEndian, lmp.Pnts[i].Clr)
-			if err != nil {
-				log.Println("ERROR: binary write of color to buffer failed:", err)
-			}
-		}
+// Clrs - current colors of lmp leds
+func (lmp *Lmp) Clrs() [LampSize]RGB {
+	clrs := [LampSize]RGB{}
+	for i := 0; i < LampSize; i++ {
+		clrs[i] = lmp.Pnts[i].Clr
+	}
+	return clrs
+}
 
-		// connect to local udp server on port 3333
-		dst := ip + ":" + UDPPort
-		conn, err := net.Dial("udp", dst)
-		if err != nil {
-			log.Printf("ERROR: failed get udp conn for lamp at %v: %v", dst, err)
+// LmpPckt - encode led colors of given bit depth as lamp udp packet of big
+// endian 16 bit rgb, or a byte per channel for 8 bit protocols
+func LmpPckt(clrs [LampSize]RGB, bits int) []byte {
+	buf := new(bytes.Buffer)
+	if bits <= 8 {
+		for i := 0; i < LampSize; i++ {
+			buf.Write([]byte{byte(clrs[i][0]), byte(clrs[i][1]), byte(clrs[i][2])})
 		}
-		defer conn.Close()
-
-		// write buffer to server
-		_, err = conn.Write(buf.Bytes())
+		return buf.Bytes()
+	}
+	for i := 0; i < LampSize; i++ {
+		err := binary.Write(buf, binary.BigEndian, clrs[i])
 		if err != nil {
-			// log.Printf("ERROR: failed to send udp message to lamp at %v: %v", dst, err)
+			log.Println("ERROR: binary write of color to buffer failed:", err)
 		}
+	}
+	return buf.Bytes()
+}
 
-		// log.Printf("udpcast: %v", buf.Bytes())
+// SendPckt - send udp packet to lamp at dst host:port
+func SendPckt(dst string, pkt []byte) error {
+	conn, err := net.Dial("udp", dst)
+	if err != nil {
+		log.Printf("ERROR: failed get udp conn for lamp at %v: %v", dst, err)
+		return err
 	}
+	defer conn.Close()
+
+	// write buffer to lamp, failures are common while lamps boot so dont log
+	_, err = conn.Write(pkt)
+	return err
 }
 
 // Cast - routine to loop & update leds
-func (blnkr *Blnkr) Cast(rgbch chan VtClr) {
-	lastclr := RGB{}
-	clrstrk := 0
-
-	// trigger wave updates
-	uch := make(chan bool)
-	go Metronome(uch, UpdateDelay)
+// mode changes from the admin dashboard are received over cmdch
+func (blnkr *Blnkr) Cast(rgbch chan VtClr, cmdch chan string, thmch chan *Theme, tchch chan int, wrdch chan []Wrd) {
+	lastfrm := time.Now()
+	if blnkr.Strt == 0 {
+		blnkr.Strt = NowMs()
+	}
 
-	// trigger new waves
-	wch := make(chan bool)
-	go Metronome(wch, WvDelay)
+	// trigger wave updates, frames are timed from Strt so they fall on the
+	// same journal times as in replay
+	tckr := time.NewTicker(UpdateDelay * time.Millisecond)
+	defer tckr.Stop()
 
 	for {
 		select {
 
 		// create new outwave in word color when vote received
 		case vc := <-rgbch:
-
-			c := vc.Clr
-			edx := blnkr.StnMp[vc.Stn]
-			blnkr.makeOutWv(edx, c)
-
-			// track vote streaks
-			if c == lastclr {
-				clrstrk++
-			} else {
-				clrstrk = 1
-				lastclr = c
+			blnkr.Vote(vc)
+
+		// switch mode or start / stop recording
+		case cmd := <-cmdch:
+			blnkr.Cmd(cmd)
+
+		// switch lighting theme for a new round
+		case thm := <-thmch:
+			blnkr.Thm = thm
+
+		// wake from attract when a station is touched
+		case _ = <-tchch:
+			blnkr.Touch()
+
+		// keep current words for attract colors
+		case ws := <-wrdch:
+			blnkr.Wrds = ws
+
+		// update waves & udpcast every frame that is due, catching up after stalls
+		case _ = <-tckr.C:
+			for blnkr.Strt+(blnkr.Frm+1)*UpdateDelay <= NowMs() {
+				strt := time.Now()
+				blnkr.Render()
+				blnkr.UDPCast()
+				blnkr.preview()
+				blnkr.Stts.Frame(strt.Sub(lastfrm), time.Since(strt))
+				lastfrm = strt
 			}
+		}
+	}
+}
 
-		// update waves & udpcast
-		case _ = <-uch:
-			blnkr.updateWvs()
-			blnkr.UDPCast()
-
-		// generate new inwaves
-		case _ = <-wch:
-			if clrstrk >= StrkThrsh {
-				blnkr.makeInWv(lastclr)
-			} else {
-				blnkr.makeInWv(WvClr)
+// Cmd - switch mode or scene, start / stop recording or reload calibration
+func (blnkr *Blnkr) Cmd(cmd string) {
+	switch cmd {
+	case BlnkRldClbr:
+		blnkr.LoadClbr()
+	case BlnkRecord:
+		blnkr.StartRec(fmt.Sprintf("rec_%v.blkr", time.Now().Format("20060102_150405")))
+	case BlnkStopRecord:
+		blnkr.StopRec()
+	default:
+		if blnkr.scnCmd(cmd) {
+			return
+		}
+		// run hands the mode back to the show program, others hold over it
+		md := cmd
+		blnkr.OprMd = cmd
+		if cmd == BlnkRun {
+			blnkr.OprMd = ""
+			if blnkr.ShwMd != "" {
+				md = blnkr.ShwMd
 			}
 		}
+		log.Printf("blnkr mode: %v", md)
+		blnkr.Fade(DfltTrns, nil)
+		blnkr.Md = md
+		blnkr.Stts.SetMd(md)
+	}
+}
+
+// Vote - start outwave from station epicenter in vote color & track streaks
+func (blnkr *Blnkr) Vote(vc VtClr) {
+	c := vc.Clr
+	edx := blnkr.StnMp[vc.Stn]
+	blnkr.Touch()
+	blnkr.makeOutWv(edx, c)
+	blnkr.emit(edx, c)
+
+	// track vote streaks
+	if c == blnkr.LstClr {
+		blnkr.ClrStrk++
+	} else {
+		blnkr.ClrStrk = 1
+		blnkr.LstClr = c
+	}
+}
+
+// InWv - start inwave in streak color if streak is long enough, else default
+// or theme color
+func (blnkr *Blnkr) InWv() {
+	thrsh, clr := StrkThrsh, WvClr
+	if blnkr.Thm != nil && blnkr.Thm.StrkThrsh > 0 {
+		thrsh = blnkr.Thm.StrkThrsh
+	}
+	if blnkr.Thm != nil && blnkr.Thm.WvClr != nil {
+		clr = *blnkr.Thm.WvClr
+	}
+
+	if blnkr.ClrStrk >= thrsh {
+		blnkr.makeInWv(blnkr.LstClr)
+	} else {
+		blnkr.makeInWv(clr)
+	}
+}
+
+// Render - advance waves by one frame & set pnt colors for current mode,
+// starting inwaves every WvDelay of frame time
+func (blnkr *Blnkr) Render() {
+	if t := (blnkr.Frm + 1) * UpdateDelay; t/WvDelay != (t-UpdateDelay)/WvDelay {
+		blnkr.InWv()
 	}
+	blnkr.show()
+	blnkr.updateWvs() // keep waves moving in every mode
+	switch blnkr.Md {
+	case BlnkRun:
+		blnkr.attract()
+	case BlnkTest:
+		blnkr.testPttrn()
+	case BlnkBlank:
+		blnkr.fill(RGB{})
+	case BlnkClbr:
+		blnkr.clbrPttrn()
+	}
+	blnkr.trans()
+	blnkr.dim()
+	blnkr.calibrate()
+	blnkr.play()
+	blnkr.limit()
+	blnkr.quantize()
+	blnkr.Frm++
 }
 
 func (blnkr *Blnkr) makeInWv(clr RGB) {
@@ -278,13 +476,14 @@ func (wv *Wv) Pdf(x float64) float64 {
 	return e / m
 }
 
-// ColorAt - get color of wave at given radius
-func (wv *Wv) ColorAt(x float64) RGB {
+// ColorAt - get color of wave at given radius, kept as float so faint wave
+// edges dont truncate to black
+func (wv *Wv) ColorAt(x float64) FRGB {
 	dlta := math.Abs(wv.Mn - x) // get distance from wave mean to position
 	dlta = dlta / wv.Xs         // divide distance by wave xscale
 	y := wv.Pdf(dlta) * wv.Ys
 
-	nwclr := wv.Clr.Dim(y)
+	nwclr := wv.Clr.ToF().Dim(y)
 
 	// if y > 0.1 {
 	// 	fmt.Printf("{%.2f %v}", y, nwclr)
@@ -313,20 +512,32 @@ func (blnkr *Blnkr) updateWvs() {
 		blnkr.Wvs[edx] = nwwvs
 	}
 
+	blnkr.updatePrtcls()
+
 	// apply waves to lamp points
+	blnkr.composite(blnkr.Scn)
+}
+
+// set every pnt in every lmp to color
+func (blnkr *Blnkr) fill(clr RGB) {
 	for _, lmp := range blnkr.Lmps {
 		for i := 0; i < LampSize; i++ {
-			clr := RGB{} // start with zeroed color
-			for edx, wvs := range blnkr.Wvs {
-				for _, wv := range wvs {
-					r := float64(0)
-					if len(lmp.Pnts[i].Mres) > 0 {
-						r = lmp.Pnts[i].Mres[edx]
-					}
-					wvclr := wv.ColorAt(r)
-					clr = clr.Add(wvclr)
-				}
-				lmp.Pnts[i].Clr = clr
+			lmp.Pnts[i].F = clr.ToF()
+		}
+	}
+}
+
+// step all lmps through test colors with a chase showing led index order
+func (blnkr *Blnkr) testPttrn() {
+	stp := blnkr.Frm / TestStep
+	clr := TestClrs[stp%int64(len(TestClrs))]
+	chs := int(blnkr.Frm % LampSize) // index of led lit at full while chasing
+	for _, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			if i == chs {
+				lmp.Pnts[i].F = clr.ToF()
+			} else {
+				lmp.Pnts[i].F = clr.ToF().Dim(0.25)
 			}
 		}
 	}
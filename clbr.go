@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// ClbrFile - calibration file name, kept beside the led position file
+const ClbrFile = "led_calibration.json"
+
+// ClbrStep - # of frames each calibration reference color is shown
+const ClbrStep = 90
+
+// ClbrClrs - reference colors for calibration mode: full, mid & low white
+// to check white balance & gamma, then the primaries
+var ClbrClrs = []RGB{
+	{0xfff, 0xfff, 0xfff},
+	{0x800, 0x800, 0x800},
+	{0x200, 0x200, 0x200},
+	{0xfff, 0x000, 0x000},
+	{0x000, 0xfff, 0x000},
+	{0x000, 0x000, 0xfff},
+}
+
+// LmpClbr - rgb gains of a lamp & optionally of single leds in it, by index
+type LmpClbr struct {
+	Gain *[3]float64        `json:"gain,omitempty"`
+	Leds map[int][3]float64 `json:"leds,omitempty"`
+}
+
+// Clbr - calibration from rendered colors to lamp output: a gamma curve per
+// channel, then global, per lamp & per led rgb gains
+type Clbr struct {
+	Gamma [3]float64         `json:"gamma"`          // 1 is linear
+	Gain  *[3]float64        `json:"gain,omitempty"` // white balance of every lamp
+	Lmps  map[string]LmpClbr `json:"lamps,omitempty"`
+
+	gns map[string]*[LampSize][3]float64 // combined gains per lamp led
+}
+
+// ClbrPath - calibration file beside led position file
+func ClbrPath(ledfn string) string {
+	return filepath.Join(filepath.Dir(ledfn), ClbrFile)
+}
+
+// ReadClbr - read & check calibration file, nil without error if there is none
+func ReadClbr(fn string) (*Clbr, error) {
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cb Clbr
+	if err := json.Unmarshal(b, &cb); err != nil {
+		return nil, err
+	}
+	if err := cb.init(); err != nil {
+		return nil, err
+	}
+	return &cb, nil
+}
+
+// check values & combine gains
+func (cb *Clbr) init() error {
+	for c, g := range cb.Gamma {
+		if g == 0 {
+			cb.Gamma[c] = 1
+			continue
+		}
+		if g < 0.1 || g > 5 {
+			return fmt.Errorf("gamma %v is out of range", g)
+		}
+	}
+
+	chk := func(gn [3]float64, what string) error {
+		for _, g := range gn {
+			if g < 0 || g > 4 {
+				return fmt.Errorf("%v gain %v is out of range", what, g)
+			}
+		}
+		return nil
+	}
+	gl := [3]float64{1, 1, 1}
+	if cb.Gain != nil {
+		gl = *cb.Gain
+		if err := chk(gl, "global"); err != nil {
+			return err
+		}
+	}
+
+	cb.gns = make(map[string]*[LampSize][3]float64)
+	for ip, lc := range cb.Lmps {
+		lg := [3]float64{1, 1, 1}
+		if lc.Gain != nil {
+			lg = *lc.Gain
+			if err := chk(lg, ip); err != nil {
+				return err
+			}
+		}
+		gns := [LampSize][3]float64{}
+		for i := range gns {
+			dg, has := lc.Leds[i]
+			if !has {
+				dg = [3]float64{1, 1, 1}
+			}
+			for c := 0; c < 3; c++ {
+				gns[i][c] = gl[c] * lg[c] * dg[c]
+			}
+		}
+		for i, dg := range lc.Leds {
+			if i < 0 || i >= LampSize {
+				return fmt.Errorf("lamp %v has no led %v", ip, i)
+			}
+			if err := chk(dg, fmt.Sprintf("%v led %v", ip, i)); err != nil {
+				return err
+			}
+		}
+		cb.gns[ip] = &gns
+	}
+
+	// lamps without their own entry get the global gains
+	gns := [LampSize][3]float64{}
+	for i := range gns {
+		gns[i] = gl
+	}
+	cb.gns[""] = &gns
+	return nil
+}
+
+// Apply - calibrated output colors for leds of lamp at ip
+func (cb *Clbr) Apply(ip string, clrs [LampSize]FRGB) [LampSize]FRGB {
+	if cb == nil {
+		return clrs
+	}
+	gns, has := cb.gns[ip]
+	if !has {
+		gns = cb.gns[""]
+	}
+	for i := range clrs {
+		for c := 0; c < 3; c++ {
+			v := math.Max(0, math.Min(1, clrs[i][c]))
+			clrs[i][c] = math.Min(1, math.Pow(v, cb.Gamma[c])*gns[i][c])
+		}
+	}
+	return clrs
+}
+
+// LoadClbr - (re)load calibration from blnkr calibration file
+func (blnkr *Blnkr) LoadClbr() {
+	if blnkr.ClbrFn == "" {
+		return
+	}
+	cb, err := ReadClbr(blnkr.ClbrFn)
+	if err != nil {
+		log.Printf("ERROR: cant use calibration from %v, keeping current: %v", blnkr.ClbrFn, err)
+		return
+	}
+	if cb == nil {
+		log.Printf("no %v, sending colors uncalibrated", blnkr.ClbrFn)
+	} else {
+		log.Printf("calibrating colors with %v", blnkr.ClbrFn)
+		for ip := range cb.Lmps {
+			if _, has := blnkr.Lmps[ip]; !has {
+				log.Printf("WARNING: calibration for unknown lamp %v", ip)
+			}
+		}
+	}
+	blnkr.Clbr = cb
+}
+
+// calibrate - calibrated float colors of every lmp as the lamps will show them
+func (blnkr *Blnkr) calibrate() {
+	for ip, lmp := range blnkr.Lmps {
+		lmp.Cal = blnkr.Clbr.Apply(ip, lmp.Flts())
+	}
+}
+
+// Out - calibrated colors of lmp leds as sent to the lamp
+func (blnkr *Blnkr) Out(lmp *Lmp) [LampSize]RGB {
+	return lmp.Out
+}
+
+// show each calibration reference color on every led in turn
+func (blnkr *Blnkr) clbrPttrn() {
+	stp := blnkr.Frm / ClbrStep
+	blnkr.fill(ClbrClrs[stp%int64(len(ClbrClrs))])
+}
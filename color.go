@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HSV - hue in degrees 0-360, saturation & value 0-1
+type HSV struct{ H, S, V float64 }
+
+// HSL - hue in degrees 0-360, saturation & lightness 0-1
+type HSL struct{ H, S, L float64 }
+
+// OKLab - perceptual lightness, green-red & blue-yellow axes
+type OKLab struct{ L, A, B float64 }
+
+// ParseHex - parse hex color: #rgb & #rrggbb scale up to 12 bit, #rrrgggbbb
+// is 12 bit as is; the # is optional
+func ParseHex(s string) (RGB, error) {
+	var clr RGB
+	h := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	n := len(h) / 3
+	if len(h)%3 != 0 || n < 1 || n > 3 {
+		return clr, fmt.Errorf("hex color '%v' is not 3, 6 or 9 digits", s)
+	}
+	mx := uint64(1)<<(4*uint(n)) - 1
+	for c := 0; c < 3; c++ {
+		v, err := strconv.ParseUint(h[c*n:(c+1)*n], 16, 16)
+		if err != nil {
+			return clr, fmt.Errorf("bad hex color '%v'", s)
+		}
+		clr[c] = uint16((v*0xfff + mx/2) / mx)
+	}
+	return clr, nil
+}
+
+// Hex - color as #rrggbb if that reads back the same, else 12 bit #rrrgggbbb
+func (rgb RGB) Hex() string {
+	s := fmt.Sprintf("#%02x%02x%02x", (uint32(rgb[0])*0xff+0x7ff)/0xfff, (uint32(rgb[1])*0xff+0x7ff)/0xfff, (uint32(rgb[2])*0xff+0x7ff)/0xfff)
+	if back, _ := ParseHex(s); back == rgb {
+		return s
+	}
+	return fmt.Sprintf("#%03x%03x%03x", rgb[0], rgb[1], rgb[2])
+}
+
+// UnmarshalJSON - read color as array of 12 bit values or hex string
+func (rgb *RGB) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		clr, err := ParseHex(s)
+		if err != nil {
+			return err
+		}
+		*rgb = clr
+		return nil
+	}
+	var vs [3]uint16
+	if err := json.Unmarshal(b, &vs); err != nil {
+		return err
+	}
+	*rgb = RGB(vs)
+	return nil
+}
+
+// HSV - color as hue, saturation & value
+func (rgb RGB) HSV() HSV {
+	f := rgb.ToF()
+	mx := math.Max(f[0], math.Max(f[1], f[2]))
+	mn := math.Min(f[0], math.Min(f[1], f[2]))
+	hsv := HSV{H: hue(f, mx, mn), V: mx}
+	if mx > 0 {
+		hsv.S = (mx - mn) / mx
+	}
+	return hsv
+}
+
+// RGB - hsv as 12 bit color
+func (hsv HSV) RGB() RGB {
+	c := hsv.V * hsv.S
+	return fromHue(hsv.H, c, hsv.V-c).RGB()
+}
+
+// HSL - color as hue, saturation & lightness
+func (rgb RGB) HSL() HSL {
+	f := rgb.ToF()
+	mx := math.Max(f[0], math.Max(f[1], f[2]))
+	mn := math.Min(f[0], math.Min(f[1], f[2]))
+	hsl := HSL{H: hue(f, mx, mn), L: (mx + mn) / 2}
+	if d := 1 - math.Abs(2*hsl.L-1); d > 0 {
+		hsl.S = (mx - mn) / d
+	}
+	return hsl
+}
+
+// RGB - hsl as 12 bit color
+func (hsl HSL) RGB() RGB {
+	c := (1 - math.Abs(2*hsl.L-1)) * hsl.S
+	return fromHue(hsl.H, c, hsl.L-c/2).RGB()
+}
+
+// hue in degrees of float color with channel max & min
+func hue(f FRGB, mx, mn float64) float64 {
+	d := mx - mn
+	if d == 0 {
+		return 0
+	}
+	var h float64
+	switch mx {
+	case f[0]:
+		h = math.Mod((f[1]-f[2])/d, 6)
+	case f[1]:
+		h = (f[2]-f[0])/d + 2
+	default:
+		h = (f[0]-f[1])/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// float color of hue with chroma c, lifted by m on every channel
+func fromHue(h, c, m float64) FRGB {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var f FRGB
+	switch {
+	case h < 60:
+		f = FRGB{c, x, 0}
+	case h < 120:
+		f = FRGB{x, c, 0}
+	case h < 180:
+		f = FRGB{0, c, x}
+	case h < 240:
+		f = FRGB{0, x, c}
+	case h < 300:
+		f = FRGB{x, 0, c}
+	default:
+		f = FRGB{c, 0, x}
+	}
+	for i := range f {
+		f[i] += m
+	}
+	return f
+}
+
+// Lin - color in linear light, undoing the srgb curve colors are authored in
+func (rgb RGB) Lin() FRGB {
+	f := rgb.ToF()
+	for c, v := range f {
+		if v <= 0.04045 {
+			f[c] = v / 12.92
+		} else {
+			f[c] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return f
+}
+
+// FromLin - linear light color as 12 bit srgb color
+func FromLin(f FRGB) RGB {
+	for c, v := range f {
+		v = math.Max(0, math.Min(1, v))
+		if v <= 0.0031308 {
+			f[c] = v * 12.92
+		} else {
+			f[c] = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+	}
+	return f.RGB()
+}
+
+// OKLab - color in perceptual oklab space
+func (rgb RGB) OKLab() OKLab {
+	f := rgb.Lin()
+	l := math.Cbrt(0.4122214708*f[0] + 0.5363325363*f[1] + 0.0514459929*f[2])
+	m := math.Cbrt(0.2119034982*f[0] + 0.6806995451*f[1] + 0.1073969566*f[2])
+	s := math.Cbrt(0.0883024619*f[0] + 0.2817188376*f[1] + 0.6299787005*f[2])
+	return OKLab{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// RGB - oklab as 12 bit color, clipped to gamut
+func (lab OKLab) RGB() RGB {
+	l := lab.L + 0.3963377774*lab.A + 0.2158037573*lab.B
+	m := lab.L - 0.1055613458*lab.A - 0.0638541728*lab.B
+	s := lab.L - 0.0894841775*lab.A - 1.2914855480*lab.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return FromLin(FRGB{
+		4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	})
+}
+
+// Lerp - color t of the way from rgb to o, 0-1, through oklab so midpoints
+// keep their brightness & dont go muddy
+func (rgb RGB) Lerp(o RGB, t float64) RGB {
+	a, b := rgb.OKLab(), o.OKLab()
+	return OKLab{
+		a.L + (b.L-a.L)*t,
+		a.A + (b.A-a.A)*t,
+		a.B + (b.B-a.B)*t,
+	}.RGB()
+}
+
+// Rotate - shift hue by deg degrees at the same perceptual lightness & chroma
+func (rgb RGB) Rotate(deg float64) RGB {
+	lab := rgb.OKLab()
+	sn, cs := math.Sincos(deg * math.Pi / 180)
+	return OKLab{lab.L, lab.A*cs - lab.B*sn, lab.A*sn + lab.B*cs}.RGB()
+}
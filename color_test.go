@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in   string
+		want RGB
+		err  bool
+	}{
+		{"#000", RGB{0, 0, 0}, false},
+		{"#fff", RGB{0xfff, 0xfff, 0xfff}, false},
+		{"#f80", RGB{0xfff, 0x888, 0}, false},
+		{"#ffffff", RGB{0xfff, 0xfff, 0xfff}, false},
+		{"#ff8000", RGB{0xfff, 0x808, 0}, false},
+		{"#010203", RGB{16, 32, 48}, false},
+		{"#fff800000", RGB{0xfff, 0x800, 0}, false},
+		{"#123456789", RGB{0x123, 0x456, 0x789}, false},
+		{"ff8000", RGB{0xfff, 0x808, 0}, false},
+		{" #FF8000 ", RGB{0xfff, 0x808, 0}, false},
+		{"", RGB{}, true},
+		{"#", RGB{}, true},
+		{"#ff", RGB{}, true},
+		{"#ff80", RGB{}, true},
+		{"#fffffffffffa", RGB{}, true},
+		{"#ggg", RGB{}, true},
+		{"#-12", RGB{}, true},
+		{"##fff", RGB{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHex(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		in   RGB
+		want string
+	}{
+		{RGB{0, 0, 0}, "#000000"},
+		{RGB{0xfff, 0x808, 0}, "#ff8000"},
+		{RGB{0x123, 0x456, 0x789}, "#123456789"},
+		{RGB{1, 0, 0}, "#001000000"},
+	}
+	for _, tt := range tests {
+		got := tt.in.Hex()
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.in, got, tt.want)
+		}
+		if back, err := ParseHex(got); err != nil || back != tt.in {
+			t.Errorf("%v: %v reads back as %v, %v", tt.in, got, back, err)
+		}
+	}
+}
+
+func TestRGBUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want RGB
+		err  bool
+	}{
+		{`[4095, 2048, 0]`, RGB{4095, 2048, 0}, false},
+		{`"#ff8000"`, RGB{0xfff, 0x808, 0}, false},
+		{`"#xyz"`, RGB{}, true},
+		{`{"r": 1}`, RGB{}, true},
+	}
+	for _, tt := range tests {
+		var got RGB
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// layer blend modes, how a layer combines with the layers below it
+const (
+	BlndAdd    = "add"      // sum, saturating
+	BlndScreen = "screen"   // brightens like add but eases off toward white
+	BlndMax    = "max"      // brighter of the two per channel
+	BlndAlpha  = "alpha"    // over, layer brightness as its coverage
+	BlndMult   = "multiply" // darkens, lights below show through layer color
+)
+
+// DfltLyrs - in-waves as background, vote out-waves over them tinting the
+// lamps they pass instead of adding up to white
+var DfltLyrs = []Lyr{
+	{Name: "in", Srcs: []int{0}, Mode: BlndAdd, Opacity: 1},
+	{Name: "out", Srcs: []int{1, 2, 3}, Mode: BlndAlpha, Opacity: 1},
+}
+
+// CurLyrs - layers blnkr composites with when not the defaults, journaled
+// so replays light the same
+var CurLyrs []Lyr
+
+// LyrMask - limits a layer to leds within a range along an axis, fading
+// out over feather on either side
+type LyrMask struct {
+	Axis   string  `json:"axis"` // x, y or z
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Fthr   float64 `json:"feather,omitempty"`
+	Invert bool    `json:"invert,omitempty"` // leds outside range instead
+}
+
+// Lyr - waves from a set of epicenters rendered together & blended onto the
+// layers below; layers are composited in order, first at the bottom
+//
+//	{"name": "out", "epicenters": [1, 2, 3], "mode": "alpha", "opacity": 0.8,
+//	 "mask": {"axis": "x", "min": 0, "max": 150, "feather": 20}}
+//
+// a layer with noise & no epicenters is a pure ambient layer, one with
+// particles & no epicenters shows votes as particles instead of waves
+type Lyr struct {
+	Name    string    `json:"name"`
+	Srcs    []int     `json:"epicenters"` // indexes of blnkr wave lists
+	Mode    string    `json:"mode"`
+	Opacity float64   `json:"opacity"`
+	Mask    *LyrMask  `json:"mask,omitempty"`
+	Noise   *NoiseCfg `json:"noise,omitempty"`     // ambient noise field under the layer's waves
+	Prtcls  bool      `json:"particles,omitempty"` // vote particles over the layer's waves
+}
+
+// ReadLyrs - read json array of layers from file
+func ReadLyrs(fn string) ([]Lyr, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var lyrs []Lyr
+	if err := json.Unmarshal(b, &lyrs); err != nil {
+		return nil, err
+	}
+	return lyrs, nil
+}
+
+// CheckLyrs - make sure layers have known modes & masks, opacities in range
+// & epicenters the blnkr has
+func CheckLyrs(lyrs []Lyr, epcntrs int) error {
+	if len(lyrs) == 0 {
+		return errors.New("no layers")
+	}
+	for i, ly := range lyrs {
+		switch ly.Mode {
+		case BlndAdd, BlndScreen, BlndMax, BlndAlpha, BlndMult:
+		default:
+			return fmt.Errorf("layer %v has unknown blend mode '%v'", i+1, ly.Mode)
+		}
+		if ly.Opacity < 0 || ly.Opacity > 1 {
+			return fmt.Errorf("layer %v opacity %v is not between 0 & 1", i+1, ly.Opacity)
+		}
+		for _, edx := range ly.Srcs {
+			if edx < 0 || edx >= epcntrs {
+				return fmt.Errorf("layer %v has no epicenter %v", i+1, edx)
+			}
+		}
+		if ly.Noise != nil {
+			if err := ly.Noise.check(); err != nil {
+				return fmt.Errorf("layer %v: %v", i+1, err)
+			}
+		}
+		if m := ly.Mask; m != nil {
+			if m.axis() < 0 {
+				return fmt.Errorf("layer %v mask axis '%v' is not x, y or z", i+1, m.Axis)
+			}
+			if m.Min > m.Max || m.Fthr < 0 {
+				return fmt.Errorf("layer %v mask range is empty or feather negative", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// SetLyrs - composite with layers from file, defaults if fn is empty
+func (blnkr *Blnkr) SetLyrs(fn string) error {
+	if fn == "" {
+		blnkr.Lyrs, CurLyrs = DfltLyrs, nil
+		return nil
+	}
+	lyrs, err := ReadLyrs(fn)
+	if err != nil {
+		return err
+	}
+	if err := CheckLyrs(lyrs, len(blnkr.Epcntrs)); err != nil {
+		return err
+	}
+	blnkr.Lyrs, CurLyrs = lyrs, lyrs
+	return nil
+}
+
+func (m *LyrMask) axis() int {
+	switch m.Axis {
+	case "x":
+		return 0
+	case "y":
+		return 1
+	case "z":
+		return 2
+	}
+	return -1
+}
+
+// Weight - how much of a layer shows at led position, 1 without a mask
+func (m *LyrMask) Weight(crds mgl64.Vec3) float64 {
+	if m == nil {
+		return 1
+	}
+	v := crds[m.axis()]
+	w := 1.0
+	switch {
+	case v < m.Min-m.Fthr || v > m.Max+m.Fthr:
+		w = 0
+	case v < m.Min:
+		w = 1 - (m.Min-v)/m.Fthr
+	case v > m.Max:
+		w = 1 - (v-m.Max)/m.Fthr
+	}
+	if m.Invert {
+		w = 1 - w
+	}
+	return w
+}
+
+// Blend - combine layer color f onto b with opacity a, channels 0-1
+func (ly *Lyr) Blend(b FRGB, f FRGB, a float64) FRGB {
+	cov := math.Max(f[0], math.Max(f[1], f[2]))
+	for c := 0; c < 3; c++ {
+		var v float64
+		switch ly.Mode {
+		case BlndAdd:
+			v = math.Min(1, b[c]+f[c])
+		case BlndScreen:
+			v = 1 - (1-b[c])*(1-f[c])
+		case BlndMax:
+			v = math.Max(b[c], f[c])
+		case BlndAlpha: // wave colors are premultiplied by their brightness
+			v = f[c] + b[c]*(1-cov)
+		case BlndMult:
+			v = b[c] * f[c]
+		}
+		b[c] += (v - b[c]) * a
+	}
+	return b
+}
+
+// set lmp pnt colors by compositing wave layers of scene over its background,
+// blnkr layers over black without a scene or if it has no layers
+func (blnkr *Blnkr) composite(sc *Scene) {
+	lyrs, bg := blnkr.Lyrs, FRGB{}
+	if sc != nil && len(sc.Lyrs) > 0 {
+		lyrs = sc.Lyrs
+	}
+	if sc != nil && sc.Bg != nil {
+		bg = sc.Bg.ToF()
+	}
+	now := NowMs()
+	for _, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			pnt := &lmp.Pnts[i]
+			clr := bg
+			for l := range lyrs {
+				ly := &lyrs[l]
+				a := ly.Opacity * ly.Mask.Weight(pnt.Crds)
+				if a <= 0 {
+					continue
+				}
+
+				// waves within a layer add up as they always have
+				f := FRGB{}
+				if ly.Noise != nil {
+					f = ly.Noise.At(pnt.Crds, now)
+				}
+				for _, edx := range ly.Srcs {
+					r := float64(0)
+					if len(pnt.Mres) > 0 {
+						r = pnt.Mres[edx]
+					}
+					for _, wv := range blnkr.Wvs[edx] {
+						f = f.Add(wv.ColorAt(r))
+					}
+				}
+				if ly.Prtcls {
+					f = f.Add(pnt.Prt)
+				}
+				clr = ly.Blend(clr, f, a)
+			}
+			pnt.F = clr
+		}
+	}
+}
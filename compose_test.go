@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func TestBlend(t *testing.T) {
+	b := FRGB{0.5, 0.2, 0}
+	tests := []struct {
+		mode string
+		f    FRGB
+		a    float64
+		want FRGB
+	}{
+		{BlndAdd, FRGB{0.25, 0.9, 0.5}, 1, FRGB{0.75, 1, 0.5}},
+		{BlndAdd, FRGB{0.25, 0.9, 0.5}, 0.5, FRGB{0.625, 0.6, 0.25}},
+		{BlndScreen, FRGB{0.5, 0.5, 1}, 1, FRGB{0.75, 0.6, 1}},
+		{BlndMax, FRGB{0.25, 0.4, 0}, 1, FRGB{0.5, 0.4, 0}},
+		{BlndAlpha, FRGB{0, 0, 0.5}, 1, FRGB{0.25, 0.1, 0.5}}, // half coverage lets half through
+		{BlndAlpha, FRGB{1, 0, 0}, 1, FRGB{1, 0, 0}},          // full coverage hides below
+		{BlndAlpha, FRGB{}, 1, b},                             // nothing shows below
+		{BlndMult, FRGB{0.5, 1, 1}, 1, FRGB{0.25, 0.2, 0}},
+		{BlndMult, FRGB{}, 0, b}, // no opacity leaves below as is
+	}
+	for _, tt := range tests {
+		ly := Lyr{Mode: tt.mode}
+		got := ly.Blend(b, tt.f, tt.a)
+		for c := 0; c < 3; c++ {
+			if !near(got[c], tt.want[c]) {
+				t.Errorf("%v %v at %v: got %v, want %v", tt.mode, tt.f, tt.a, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLyrMaskWeight(t *testing.T) {
+	m := &LyrMask{Axis: "y", Min: 10, Max: 20, Fthr: 4}
+	hard := &LyrMask{Axis: "x", Min: 10, Max: 20}
+	inv := &LyrMask{Axis: "y", Min: 10, Max: 20, Fthr: 4, Invert: true}
+	tests := []struct {
+		name string
+		m    *LyrMask
+		v    float64
+		want float64
+	}{
+		{"no mask", nil, 100, 1},
+		{"inside", m, 15, 1},
+		{"at min", m, 10, 1},
+		{"half feather below", m, 8, 0.5},
+		{"quarter feather above", m, 23, 0.25},
+		{"end of feather", m, 24, 0},
+		{"past feather", m, 30, 0},
+		{"hard edge in", hard, 20, 1},
+		{"hard edge out", hard, 20.01, 0},
+		{"inverted inside", inv, 15, 0},
+		{"inverted feather", inv, 7, 0.75},
+		{"inverted outside", inv, 0, 1},
+	}
+	for _, tt := range tests {
+		crds := mgl64.Vec3{tt.v, tt.v, 0}
+		if tt.m != nil && tt.m.Axis == "y" {
+			crds = mgl64.Vec3{-1000, tt.v, -1000} // other axes dont count
+		}
+		if got := tt.m.Weight(crds); !near(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log"
+)
+
+// CycleDelay - delay between cycling words in milliseconds when slots have
+// no timers of their own
+const CycleDelay = 20000
+
+// CycleTick - how often slot timers are checked in milliseconds
+const CycleTick = 1000
+
+// TchTmout - touches open longer than this are taken as a missed end touch
+const TchTmout = int64(30000)
+
+// StttrMs - end touches this soon after their start are stutter, the touch
+// stays open for its real end
+const StttrMs = int64(200)
+
+// SlotDelay - mean ms a word stays up in a slot, 0 cycles one slot every
+// CycleDelay instead
+var SlotDelay = int64(120000)
+
+// SlotJttr - slot timers vary by up to this fraction of SlotDelay either way
+var SlotJttr = 0.25
+
+// CycleVts - cycle a slot once it got this many votes, 0 never
+var CycleVts = 0
+
+// OpnTch - touch in progress on a slot & what was up when it started
+type OpnTch struct {
+	Strt int64 // 0 if no touch is open
+	Wrd  Wrd
+	Opp  Wrd // word on the other side of the station
+}
+
+// Tick - ms between word cycle checks
+func Tick() int64 {
+	if SlotDelay > 0 {
+		return CycleTick
+	}
+	return CycleDelay
+}
+
+// nxtDue - jittered time slots posted at stmp are next due
+func (w Wrdr) nxtDue(stmp int64) int64 {
+	if SlotDelay <= 0 {
+		return 0 // cycled by metronome, dont draw from rnd
+	}
+	jttr := SlotJttr * (2*w.Rnd.Float64() - 1)
+	return stmp + int64(float64(SlotDelay)*(1+jttr))
+}
+
+// opn - if somebody is touching slot at now
+func (w Wrdr) opn(wrddx int, now int64) bool {
+	strt := w.Opn[wrddx].Strt
+	return strt > 0 && now-strt < TchTmout
+}
+
+// DueSlots - groups of slots to cycle now: slots whose timer ran out or that
+// got enough votes, or a slot from the pick strategy without timers, both
+// sides of a station together for pairs; slots being touched wait
+func (w Wrdr) DueSlots(now int64) [][]int {
+	grp := 1
+	if w.Pckr.Pairs() {
+		grp = 2
+	}
+
+	grps := [][]int{}
+	busy := func(dxs []int) bool {
+		for _, wrddx := range dxs {
+			if w.opn(wrddx, now) {
+				return true
+			}
+			for _, g := range grps {
+				if hasDx(g, wrddx) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if SlotDelay <= 0 {
+		dxs := w.Pckr.Slots(w)
+		if busy(dxs) {
+			log.Printf("skipping cycle of slots %v, touch open", dxs)
+		} else {
+			grps = append(grps, dxs)
+		}
+	}
+
+	for i := 0; i < len(w.Wrds); i += grp {
+		dxs := []int{}
+		due := false
+		for j := i; j < i+grp; j++ {
+			dxs = append(dxs, j)
+			due = due || (SlotDelay > 0 && now >= w.Due[j]) || (CycleVts > 0 && w.Vts[j] >= CycleVts)
+		}
+		if due && !busy(dxs) {
+			grps = append(grps, dxs)
+		}
+	}
+	return grps
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+)
+
+// DfltBits - bit depth of the teensy lamps
+const DfltBits = 12
+
+// OutBits - bit depth of values sent to the lamps, 12 for the teensy lamps,
+// 8 for lamps speaking 8 bit protocols; 8 or less sends a byte per channel
+// instead of 16 bit words
+var OutBits = DfltBits
+
+// Dither - carry each led's rounding error into its next frame so slow fades
+// step through levels in between instead of jumping, off unless asked for
+var Dither = false
+
+// FRGB - float color, 0-1 per channel
+type FRGB [3]float64
+
+// ToF - 12 bit color as float color
+func (rgb RGB) ToF() FRGB {
+	return FRGB{float64(rgb[0]) / 0xfff, float64(rgb[1]) / 0xfff, float64(rgb[2]) / 0xfff}
+}
+
+// Add - add two float colors, saturating
+func (f FRGB) Add(o FRGB) FRGB {
+	for c := 0; c < 3; c++ {
+		f[c] = math.Min(1, f[c]+o[c])
+	}
+	return f
+}
+
+// Dim - multiply float color by float
+func (f FRGB) Dim(k float64) FRGB {
+	for c := 0; c < 3; c++ {
+		f[c] = math.Min(1, f[c]*k)
+	}
+	return f
+}
+
+// RGB - round float color to 12 bit color
+func (f FRGB) RGB() RGB {
+	return RGB{qntz(f[0], 0xfff), qntz(f[1], 0xfff), qntz(f[2], 0xfff)}
+}
+
+func qntz(v float64, mx float64) uint16 {
+	return uint16(math.Round(math.Max(0, math.Min(1, v)) * mx))
+}
+
+// Flts - float render buffer of lmp leds
+func (lmp *Lmp) Flts() [LampSize]FRGB {
+	flts := [LampSize]FRGB{}
+	for i := 0; i < LampSize; i++ {
+		flts[i] = lmp.Pnts[i].F
+	}
+	return flts
+}
+
+// quantize - turn every lmp float buffer into 12 bit pnt colors & calibrated
+// floats into output at OutBits, the one place rendered colors are rounded
+func (blnkr *Blnkr) quantize() {
+	mx := float64(int(1)<<uint(OutBits) - 1)
+	for _, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			pnt := &lmp.Pnts[i]
+			pnt.Clr = pnt.F.RGB()
+			for c := 0; c < 3; c++ {
+				v := math.Max(0, math.Min(1, lmp.Cal[i][c])) * mx
+				if !Dither || v == 0 {
+					pnt.Err[c] = 0 // black stays black, no stray flicker
+					lmp.Out[i][c] = uint16(math.Round(v))
+					continue
+				}
+				q := math.Round(v + pnt.Err[c])
+				q = math.Max(0, math.Min(mx, q))
+				pnt.Err[c] += v - q
+				lmp.Out[i][c] = uint16(q)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+)
+
+// blnkr of one lamp with every led rendered & calibrated at v
+func qntzBlnkr(v float64) (*Blnkr, *Lmp) {
+	lmp := &Lmp{IP: "a"}
+	setQntz(lmp, v)
+	return &Blnkr{Lmps: map[string]*Lmp{"a": lmp}}, lmp
+}
+
+func setQntz(lmp *Lmp, v float64) {
+	for i := 0; i < LampSize; i++ {
+		lmp.Pnts[i].F = FRGB{v, v, v}
+		lmp.Cal[i] = FRGB{v, v, 0}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	defer func(b int, d bool) { OutBits, Dither = b, d }(OutBits, Dither)
+	tests := []struct {
+		name string
+		bits int
+		v    float64
+		want uint16
+	}{
+		{"12 bit full", 12, 1, 0xfff},
+		{"12 bit half rounds up", 12, 0.5, 0x800},
+		{"12 bit black", 12, 0, 0},
+		{"8 bit full", 8, 1, 0xff},
+		{"8 bit half rounds up", 8, 0.5, 0x80},
+		{"8 bit a level", 8, 1.0 / 255, 1},
+		{"16 bit full", 16, 1, 0xffff},
+		{"over full clips", 8, 1.5, 0xff},
+		{"under black clips", 12, -0.5, 0},
+	}
+	for _, tt := range tests {
+		OutBits, Dither = tt.bits, false
+		blnkr, lmp := qntzBlnkr(tt.v)
+		blnkr.quantize()
+		if got := lmp.Out[0]; got[0] != tt.want || got[1] != tt.want || got[2] != 0 {
+			t.Errorf("%v: sent %v, want %v", tt.name, got, tt.want)
+		}
+		if lmp.Pnts[0].Clr != lmp.Pnts[0].F.RGB() || lmp.Pnts[0].Err != [3]float64{} {
+			t.Errorf("%v: color %v err %v", tt.name, lmp.Pnts[0].Clr, lmp.Pnts[0].Err)
+		}
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	defer func(b int, d bool) { OutBits, Dither = b, d }(OutBits, Dither)
+	OutBits, Dither = 8, true
+
+	// a quarter level above 100 averages out over 4 frames
+	blnkr, lmp := qntzBlnkr(100.25 / 255)
+	sum := 0
+	for f := 0; f < 4; f++ {
+		blnkr.quantize()
+		q := lmp.Out[0][0]
+		if q != 100 && q != 101 {
+			t.Fatalf("frame %v: sent %v, want 100 or 101", f, q)
+		}
+		sum += int(q)
+	}
+	if sum != 401 {
+		t.Errorf("4 frames sum to %v, want 401", sum)
+	}
+
+	// fading to black drops carried error instead of flickering
+	setQntz(lmp, 0.3/255)
+	blnkr.quantize()
+	setQntz(lmp, 0)
+	for f := 0; f < 3; f++ {
+		blnkr.quantize()
+		if lmp.Out[0] != (RGB{}) || lmp.Pnts[0].Err != [3]float64{} {
+			t.Fatalf("black frame %v: sent %v, err %v", f, lmp.Out[0], lmp.Pnts[0].Err)
+		}
+	}
+
+	// full stays full
+	setQntz(lmp, 1)
+	for f := 0; f < 3; f++ {
+		blnkr.quantize()
+		if lmp.Out[0][0] != 0xff {
+			t.Fatalf("full frame %v: sent %v", f, lmp.Out[0])
+		}
+	}
+}
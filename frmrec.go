@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// frame recording file format (.blkr):
+//   header:
+//     magic "BLKR", version byte
+//     bit depth byte of channels (version 2, version 1 is always 12)
+//     uvarint start time (unix ms)
+//     uvarint # of lamps, then per lamp: uvarint length & ip string
+//   frame records until eof:
+//     uvarint ms since previous frame (or start)
+//     uvarint # of lamps in frame, then per lamp:
+//       uvarint lamp index into header ips
+//       LampSize*3 channels packed msb first at the bit depth (r g b order),
+//       12 bit channels take two per 3 bytes
+
+// RecMagic - leading bytes of frame recording file
+const RecMagic = "BLKR"
+
+// RecVersion - version of frame recording format
+const RecVersion = 2
+
+// lmpRecSize - bytes of packed color per lamp in a frame record
+func lmpRecSize(bits int) int {
+	return (LampSize*3*bits + 7) / 8
+}
+
+// RecFrm - one recorded frame of lamp colors
+type RecFrm struct {
+	T    int64 // unix ms
+	Bits int   // bit depth of colors
+	Clrs map[string][LampSize]RGB
+}
+
+// FrmWrtr - writes frames to recording file
+type FrmWrtr struct {
+	Fn   string
+	f    *os.File
+	w    *bufio.Writer
+	ips  []string
+	idx  map[string]int
+	lst  int64 // time of last frame
+	N    int64 // # of frames written
+	bits int
+}
+
+// NewFrmWrtr - create recording file for lamps with given ips & colors of
+// given bit depth
+func NewFrmWrtr(fn string, ips []string, stmp int64, bits int) (*FrmWrtr, error) {
+	if bits < 1 || bits > 16 {
+		return nil, fmt.Errorf("cant record %v bit colors", bits)
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return nil, err
+	}
+	fw := FrmWrtr{
+		Fn:   fn,
+		f:    f,
+		w:    bufio.NewWriter(f),
+		ips:  ips,
+		idx:  make(map[string]int),
+		lst:  stmp,
+		bits: bits,
+	}
+	fw.w.WriteString(RecMagic)
+	fw.w.WriteByte(RecVersion)
+	fw.w.WriteByte(byte(bits))
+	fw.uvarint(uint64(stmp))
+	fw.uvarint(uint64(len(ips)))
+	for i, ip := range ips {
+		fw.idx[ip] = i
+		fw.uvarint(uint64(len(ip)))
+		fw.w.WriteString(ip)
+	}
+	return &fw, nil
+}
+
+func (fw *FrmWrtr) uvarint(v uint64) {
+	b := [binary.MaxVarintLen64]byte{}
+	n := binary.PutUvarint(b[:], v)
+	fw.w.Write(b[:n])
+}
+
+// Frame - append frame of colors for each lamp at time stmp
+func (fw *FrmWrtr) Frame(stmp int64, clrs map[string][LampSize]RGB) error {
+	dt := stmp - fw.lst
+	if dt < 0 {
+		dt = 0
+	}
+	fw.lst = stmp
+	fw.uvarint(uint64(dt))
+	fw.uvarint(uint64(len(clrs)))
+	for _, ip := range fw.ips { // write in header order
+		c, has := clrs[ip]
+		if !has {
+			continue
+		}
+		fw.uvarint(uint64(fw.idx[ip]))
+		fw.w.Write(packChs(c, fw.bits))
+	}
+	fw.N++
+	_, err := fw.w.Write(nil) // surface any buffered write error
+	return err
+}
+
+// Close - flush & close recording file
+func (fw *FrmWrtr) Close() error {
+	if err := fw.w.Flush(); err != nil {
+		fw.f.Close()
+		return err
+	}
+	return fw.f.Close()
+}
+
+// pack channels of given bit depth msb first, channels too big for the
+// depth are clipped to its max instead of wrapping
+func packChs(clrs [LampSize]RGB, bits int) []byte {
+	mx := uint32(1)<<uint(bits) - 1
+	buf := make([]byte, 0, lmpRecSize(bits))
+	acc, n := uint32(0), 0 // bits waiting to be written
+	for _, c := range clrs {
+		for _, ch := range c {
+			v := uint32(ch)
+			if v > mx {
+				v = mx
+			}
+			acc, n = acc<<uint(bits)|v, n+bits
+			for n >= 8 {
+				n -= 8
+				buf = append(buf, byte(acc>>uint(n)))
+			}
+		}
+	}
+	if n > 0 {
+		buf = append(buf, byte(acc<<uint(8-n)))
+	}
+	return buf
+}
+
+// unpack channels packed by packChs
+func unpackChs(buf []byte, bits int) [LampSize]RGB {
+	mx := uint32(1)<<uint(bits) - 1
+	clrs := [LampSize]RGB{}
+	acc, n, b := uint32(0), 0, 0
+	for i := range clrs {
+		for c := 0; c < 3; c++ {
+			for n < bits && b < len(buf) {
+				acc, n = acc<<8|uint32(buf[b]), n+8
+				b++
+			}
+			if n < bits {
+				return clrs // short buffer
+			}
+			n -= bits
+			clrs[i][c] = uint16(acc >> uint(n) & mx)
+		}
+	}
+	return clrs
+}
+
+// FrmRdr - reads frames from recording file
+type FrmRdr struct {
+	r     *bufio.Reader
+	IPs   []string
+	Start int64 // unix ms
+	Bits  int   // bit depth of colors
+	lst   int64
+}
+
+// NewFrmRdr - read recording header from r
+func NewFrmRdr(r io.Reader) (*FrmRdr, error) {
+	fr := FrmRdr{r: bufio.NewReader(r)}
+	hdr := make([]byte, len(RecMagic)+1)
+	if _, err := io.ReadFull(fr.r, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[:len(RecMagic)]) != RecMagic {
+		return nil, errors.New("not a frame recording")
+	}
+	switch hdr[len(RecMagic)] {
+	case 1:
+		fr.Bits = 12
+	case RecVersion:
+		b, err := fr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b < 1 || b > 16 {
+			return nil, fmt.Errorf("bad frame recording bit depth %v", b)
+		}
+		fr.Bits = int(b)
+	default:
+		return nil, fmt.Errorf("unsupported frame recording version %v", hdr[len(RecMagic)])
+	}
+	stmp, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return nil, err
+	}
+	fr.Start, fr.lst = int64(stmp), int64(stmp)
+	n, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		l, err := binary.ReadUvarint(fr.r)
+		if err != nil {
+			return nil, err
+		}
+		ip := make([]byte, l)
+		if _, err := io.ReadFull(fr.r, ip); err != nil {
+			return nil, err
+		}
+		fr.IPs = append(fr.IPs, string(ip))
+	}
+	return &fr, nil
+}
+
+// Next - read next frame, returns io.EOF at end of recording
+func (fr *FrmRdr) Next() (*RecFrm, error) {
+	dt, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return nil, err // clean io.EOF between frames
+	}
+	n, err := binary.ReadUvarint(fr.r)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	fr.lst += int64(dt)
+	frm := RecFrm{T: fr.lst, Bits: fr.Bits, Clrs: make(map[string][LampSize]RGB)}
+	buf := make([]byte, lmpRecSize(fr.Bits))
+	for i := uint64(0); i < n; i++ {
+		ldx, err := binary.ReadUvarint(fr.r)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if ldx >= uint64(len(fr.IPs)) {
+			return nil, fmt.Errorf("lamp index %v out of range", ldx)
+		}
+		if _, err := io.ReadFull(fr.r, buf); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		frm.Clrs[fr.IPs[ldx]] = unpackChs(buf, fr.Bits)
+	}
+	return &frm, nil
+}
+
+// StartRec - start recording udpcast frames to file, stops any running recording
+func (blnkr *Blnkr) StartRec(fn string) {
+	blnkr.StopRec()
+	fw, err := NewFrmWrtr(fn, blnkr.IPs, NowMs(), OutBits)
+	if err != nil {
+		log.Printf("ERROR: cant start recording: %v", err)
+		return
+	}
+	log.Printf("recording frames to %v", fn)
+	blnkr.Rcrdr = fw
+	blnkr.Stts.SetRec(fn)
+}
+
+// StopRec - stop & close running recording
+func (blnkr *Blnkr) StopRec() {
+	if blnkr.Rcrdr == nil {
+		return
+	}
+	if err := blnkr.Rcrdr.Close(); err != nil {
+		log.Printf("ERROR: failed to close recording %v: %v", blnkr.Rcrdr.Fn, err)
+	}
+	log.Printf("recorded %v frames to %v", blnkr.Rcrdr.N, blnkr.Rcrdr.Fn)
+	blnkr.Rcrdr = nil
+	blnkr.Stts.SetRec("")
+}
+
+// write current colors to running recording
+func (blnkr *Blnkr) record() {
+	if blnkr.Rcrdr == nil {
+		return
+	}
+	clrs := make(map[string][LampSize]RGB)
+	for ip, lmp := range blnkr.Lmps {
+		clrs[ip] = blnkr.Out(lmp) // as sent, so play needs no calibration
+	}
+	if err := blnkr.Rcrdr.Frame(NowMs(), clrs); err != nil {
+		log.Printf("ERROR: failed to record frame, stopping: %v", err)
+		blnkr.StopRec()
+	}
+}
+
+// PlayCmd - replay frame recording to lamps at original or scaled speed
+func PlayCmd(args []string) error {
+	fset := flag.NewFlagSet("play", flag.ExitOnError)
+	in := fset.String("in", "", "frame recording to play")
+	speed := fset.Float64("speed", 1.0, "playback speed multiplier")
+	loop := fset.Bool("loop", false, "loop forever, e.g. as an attract loop")
+	addr := fset.String("addr", "", "send every lamp to this host:port instead of its own ip")
+	fset.Parse(args)
+
+	if *in == "" {
+		return errors.New("play needs -in <recording>")
+	}
+	if *speed <= 0 {
+		return errors.New("speed must be positive")
+	}
+
+	for {
+		n, err := playRec(*in, *speed, *addr)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("no frames in %v", *in)
+		}
+		log.Printf("played %v frames from %v", n, *in)
+		if !*loop {
+			return nil
+		}
+	}
+}
+
+// play recording once, pacing frames by their recorded time deltas
+func playRec(fn string, speed float64, addr string) (int, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fr, err := NewFrmRdr(f)
+	if err != nil {
+		return 0, err
+	}
+
+	strt := time.Now()
+	n := 0
+	for {
+		frm, err := fr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		// wait until frame is due relative to start of playback
+		due := time.Duration(float64(frm.T-fr.Start)/speed) * time.Millisecond
+		if wait := due - time.Since(strt); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		for ip, clrs := range frm.Clrs {
+			dst := ip + ":" + UDPPort
+			if addr != "" {
+				dst = addr
+			}
+			SendPckt(dst, LmpPckt(clrs, fr.Bits))
+		}
+		n++
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// lamp colors counting up from v in steps of stp, wrapping at the bit depth
+func rampClrs(v, stp uint16, bits int) [LampSize]RGB {
+	mx := uint16(1<<uint(bits) - 1)
+	clrs := [LampSize]RGB{}
+	for i := range clrs {
+		for c := 0; c < 3; c++ {
+			clrs[i][c] = v & mx
+			v += stp
+		}
+	}
+	return clrs
+}
+
+func TestPackChs(t *testing.T) {
+	tests := []struct {
+		name string
+		bits int
+		clrs [LampSize]RGB
+	}{
+		{"12 bit ramp", 12, rampClrs(0, 97, 12)},
+		{"12 bit full", 12, rampClrs(0xfff, 0, 12)},
+		{"8 bit ramp", 8, rampClrs(3, 11, 8)},
+		{"13 bit above 12", 13, rampClrs(0x1000, 131, 13)},
+		{"16 bit ramp", 16, rampClrs(0xfff0, 4099, 16)},
+		{"16 bit full", 16, rampClrs(0xffff, 0, 16)},
+		{"1 bit", 1, rampClrs(0, 1, 1)},
+	}
+	for _, tt := range tests {
+		buf := packChs(tt.clrs, tt.bits)
+		if len(buf) != lmpRecSize(tt.bits) {
+			t.Errorf("%v: packed %v bytes, want %v", tt.name, len(buf), lmpRecSize(tt.bits))
+		}
+		if got := unpackChs(buf, tt.bits); got != tt.clrs {
+			t.Errorf("%v: round trip got %v, want %v", tt.name, got[:2], tt.clrs[:2])
+		}
+	}
+}
+
+// 12 bit packing stays the version 1 layout of two channels to 3 bytes
+func TestPackChs12Layout(t *testing.T) {
+	clrs := [LampSize]RGB{{0xabc, 0x123}}
+	buf := packChs(clrs, 12)
+	if want := []byte{0xab, 0xc1, 0x23}; !bytes.Equal(buf[:3], want) {
+		t.Errorf("got % x, want % x", buf[:3], want)
+	}
+}
+
+func TestPackChsClip(t *testing.T) {
+	tests := []struct {
+		bits int
+		in   uint16
+		want uint16
+	}{
+		{12, 0x1000, 0xfff},
+		{12, 0xffff, 0xfff},
+		{8, 0x100, 0xff},
+		{13, 0x1000, 0x1000},
+		{16, 0xffff, 0xffff},
+	}
+	for _, tt := range tests {
+		clrs := [LampSize]RGB{{tt.in, 0, tt.in}}
+		got := unpackChs(packChs(clrs, tt.bits), tt.bits)
+		if got[0] != (RGB{tt.want, 0, tt.want}) || got[1] != (RGB{}) {
+			t.Errorf("%v bits: %#x came back as %#x, want %#x", tt.bits, tt.in, got[0][0], tt.want)
+		}
+	}
+}
+
+func TestFrmRec(t *testing.T) {
+	ips := []string{"10.0.0.2", "10.0.0.3"}
+	for _, bits := range []int{8, 12, 16} {
+		fn := filepath.Join(t.TempDir(), "rec.blkr")
+		fw, err := NewFrmWrtr(fn, ips, 1000, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, b := rampClrs(1, 7, bits), rampClrs(0xffff, 0, bits)
+		fw.Frame(1033, map[string][LampSize]RGB{ips[0]: a, ips[1]: b})
+		fw.Frame(1066, map[string][LampSize]RGB{ips[1]: a})
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr, err := NewFrmRdr(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fr.Bits != bits || fr.Start != 1000 || len(fr.IPs) != 2 {
+			t.Errorf("%v bits: header read as %v bits, start %v, ips %v", bits, fr.Bits, fr.Start, fr.IPs)
+		}
+		frm, err := fr.Next()
+		if err != nil || frm.T != 1033 || frm.Clrs[ips[0]] != a || frm.Clrs[ips[1]] != b {
+			t.Errorf("%v bits: first frame wrong, err %v", bits, err)
+		}
+		frm, err = fr.Next()
+		if err != nil || frm.T != 1066 || len(frm.Clrs) != 1 || frm.Clrs[ips[1]] != a {
+			t.Errorf("%v bits: second frame wrong, err %v", bits, err)
+		}
+		f.Close()
+	}
+}
+
+// version 1 recordings have no bit depth & are read as 12 bit
+func TestFrmRdrV1(t *testing.T) {
+	clrs := rampClrs(5, 301, 12)
+	buf := bytes.NewBufferString(RecMagic)
+	buf.Write([]byte{1, 0x64, 1, 1, 'a'}) // version, start 100, one lamp "a"
+	buf.Write([]byte{33, 1, 0})           // 33ms later, one lamp, index 0
+	buf.Write(packChs(clrs, 12))
+
+	fr, err := NewFrmRdr(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frm, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.Bits != 12 || frm.T != 133 || frm.Clrs["a"] != clrs {
+		t.Errorf("got %v bits at %v", fr.Bits, frm.T)
+	}
+}
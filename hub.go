@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Hub - routes teensy, data client, word cycle & admin events between
+// wrdr, blnkr & data clients; the server loop & replay both drive it
+type Hub struct {
+	Stns  []int   // ordered list of vote station addresses
+	Beats []int64 // last heartbeat per vote station
+	Wrdr  Wrdr
+	Rgbch chan VtClr  // vote colors to blnkr
+	Cmdch chan string // mode commands to blnkr
+	Dcdx  map[string]DataClient
+	Sbs   map[string]map[string]bool // data client dests subscribed to each topic
+	Tllr  *Tllr                      // running vote counts
+	Stts  *Stts                      // optional status for admin dashboard
+	Jrnl  *Jrnl                      // optional event journal
+	Schd  *RndSchd                   // optional rounds of the show
+	Thmch chan *Theme                // optional round lighting themes to blnkr
+	Tchch chan int                   // optional touched stations to blnkr
+	Wrdch chan []Wrd                 // optional current words to blnkr
+}
+
+// NewHub - init hub for vote stations with wrdr & channels to blnkr
+func NewHub(stns []int, wrdr Wrdr, rgbch chan VtClr, cmdch chan string) *Hub {
+	return &Hub{
+		Stns:  stns,
+		Beats: make([]int64, len(stns)),
+		Wrdr:  wrdr,
+		Rgbch: rgbch,
+		Cmdch: cmdch,
+		Dcdx:  make(map[string]DataClient),
+		Sbs:   make(map[string]map[string]bool),
+		Tllr:  NewTllr(),
+	}
+}
+
+// Run - loop over channels & handle messages
+func (h *Hub) Run(tch chan TeensyMsg, dch chan DataClient, cych chan bool, ach chan string, pch chan []PoolWrd) {
+	for {
+		select {
+
+		// incoming teensy message channel
+		case tm := <-tch:
+			h.Teensy(tm)
+
+		// incoming data client channel
+		case dc := <-dch:
+			h.Client(dc)
+
+		// cycle words at intervals
+		case _ = <-cych:
+			h.Cycle()
+
+		// operator commands from admin dashboard
+		case cmd := <-ach:
+			h.Admin(cmd)
+
+		// word pools swapped in from admin
+		case pws := <-pch:
+			h.Pool(pws)
+		}
+	}
+}
+
+// Teensy - handle touch or heartbeat message from a vote station or data client
+func (h *Hub) Teensy(tm TeensyMsg) {
+	h.Jrnl.Teensy(tm)
+
+	// log message
+	log.Printf("received: %+v", tm)
+
+	switch tm.Flavor {
+
+	case "touch_beat": // log heartbeat
+		var sdx = int(-1)
+		for i, src := range h.Stns {
+			if src == tm.Source {
+				sdx = i
+			}
+		}
+		if sdx < 0 {
+			log.Printf("ERROR: unrecognized touch beat source '%v'\n", tm.Source)
+		} else {
+			h.Beats[sdx] = NowMs() // set last beat time to now
+			h.Stts.Beat(tm.Source, h.Beats[sdx])
+			h.Wrdr.LogBeat(tm.Source, h.Beats[sdx])
+		}
+
+	case "start_touch", "end_touch": // broadcast to data clients
+		wrdp, err := h.Wrdr.LogTouch(tm.Source, tm.Flavor, tm.Choice)
+		if err != nil {
+			log.Printf("ERROR: cant log touch: %v", err)
+			break
+		}
+		if tm.Flavor == "end_touch" {
+			select {
+			case h.Rgbch <- VtClr{tm.Source, wrdp.Clr}:
+			default:
+				log.Printf("ERROR: rgbch full!")
+			}
+		} else if h.Tchch != nil {
+			select {
+			case h.Tchch <- tm.Source:
+			default:
+				log.Printf("ERROR: tchch full!")
+			}
+		}
+
+		dm := DataMsg{
+			Source: tm.Source,
+			Flavor: tm.Flavor,
+			Choice: tm.Choice,
+			Word:   wrdp.Str,
+			Color:  []int{int(wrdp.Clr[0]), int(wrdp.Clr[1]), int(wrdp.Clr[2])},
+		}
+		h.Bcast(dm)
+		h.Stts.Touch(dm)
+
+		// count votes & tell tally subscribers
+		if tm.Flavor == "end_touch" {
+			tlly := h.Tllr.Vote(tm.Source, tm.Choice, wrdp.Str)
+			dm.Flavor = TllyTopic
+			dm.Tally = &tlly
+			h.Pub(TllyTopic, dm)
+
+			// & ranking subscribers in matchup mode
+			if h.Wrdr.Mtchr != nil {
+				dm.Flavor = MtchTopic
+				dm.Tally = nil
+				dm.Ranking = h.Wrdr.Mtchr.Rank()
+				h.Pub(MtchTopic, dm)
+			}
+		}
+
+	case "subscribe", "unsubscribe": // data client topic subscriptions
+		if tm.Dest == "" || tm.Topic == "" {
+			break
+		}
+		if h.Sbs[tm.Topic] == nil {
+			h.Sbs[tm.Topic] = make(map[string]bool)
+		}
+		if tm.Flavor == "subscribe" {
+			h.Sbs[tm.Topic][tm.Dest] = true
+		} else {
+			delete(h.Sbs[tm.Topic], tm.Dest)
+		}
+	}
+
+	fmt.Printf("@")
+}
+
+// Client - add data client & initialize it with current words
+func (h *Hub) Client(dc DataClient) {
+	log.Printf("new data client at %v", dc.Dest)
+	h.Dcdx[dc.Dest] = dc // append data client to index
+
+	for i, wrd := range h.Wrdr.Wrds {
+		src, chc := h.Wrdr.DeDex(i)
+		dm := DataMsg{
+			Source: src,
+			Choice: chc,
+			Flavor: "new_word",
+			Word:   wrd.Str,
+			Color:  []int{int(wrd.Clr[0]), int(wrd.Clr[1]), int(wrd.Clr[2])},
+		}
+		select {
+		case dc.MsgCh <- dm:
+		default:
+			log.Printf("ERROR: msgch for %v full on init!?", dc.Dest)
+		}
+	}
+
+	// frame the words with the round question
+	if Qstn != "" {
+		select {
+		case dc.MsgCh <- h.QstnMsg():
+		default:
+			log.Printf("ERROR: msgch for %v full on init!?", dc.Dest)
+		}
+	}
+
+	fmt.Printf("$")
+}
+
+// Cycle - start the next round if it is time, then pick new words for slots
+// that are due & tell data clients
+func (h *Hub) Cycle() {
+	if h.Schd.Due(NowMs()) {
+		h.Round(h.Schd.Cur + 1)
+	}
+
+	grps := h.Wrdr.DueSlots(NowMs())
+	if len(grps) == 0 && SlotDelay > 0 {
+		return // nothing due, dont journal idle ticks
+	}
+	h.Jrnl.Cycle()
+	fmt.Printf("[")
+	for _, dxs := range grps {
+		for _, dm := range h.Wrdr.Repost(dxs, NowMs()+PostDelay) { // pick new words & gen messages
+			h.Bcast(dm) // broadcast message to data clients
+		}
+	}
+	h.SetWrds()
+	fmt.Printf("]")
+}
+
+// Admin - handle operator command, forced word cycles & rounds here & the
+// rest go to blnkr
+func (h *Hub) Admin(cmd string) {
+	switch cmd {
+	case "next_round", "prev_round": // journaled as the config they change
+		if h.Schd == nil {
+			log.Printf("ERROR: no rounds to %v", cmd)
+		} else if cmd == "next_round" {
+			h.Round(h.Schd.Cur + 1)
+		} else {
+			h.Round(h.Schd.Cur - 1)
+		}
+		return
+	}
+
+	h.Jrnl.Cmd(cmd)
+	if cmd == "cycle" {
+		for _, dm := range h.Wrdr.CycleWrd() {
+			h.Bcast(dm)
+		}
+		h.SetWrds()
+		return
+	}
+	select {
+	case h.Cmdch <- cmd:
+	default:
+		log.Printf("ERROR: cmdch full! dropping '%v'", cmd)
+	}
+}
+
+// Pool - swap in new word pool & journal it
+func (h *Hub) Pool(pws []PoolWrd) {
+	SetPool(pws, len(h.Wrdr.Wrds))
+	h.Jrnl.Config(h.Stns)
+	h.Stts.SetPool(PoolWrds)
+	h.Repool()
+}
+
+// Repool - replace words that left the pool, after the post delay like cycles
+func (h *Hub) Repool() {
+	h.Wrdr.Mtchr.SetPool(WrdPool)
+	in := make(map[string]bool)
+	for _, wrd := range WrdPool {
+		in[wrd.Str] = true
+	}
+
+	grp := 1
+	if h.Wrdr.Pckr.Pairs() {
+		grp = 2
+	}
+	for i := 0; i < len(h.Wrdr.Wrds); i += grp {
+		dxs := []int{}
+		out := false
+		for j := i; j < i+grp; j++ {
+			dxs = append(dxs, j)
+			out = out || !in[h.Wrdr.Wrds[j].Str]
+		}
+		if out {
+			for _, dm := range h.Wrdr.Repost(dxs, NowMs()+PostDelay) {
+				h.Bcast(dm)
+			}
+		}
+	}
+	h.SetWrds()
+}
+
+// SetWrds - tell dashboard & blnkr the current words
+func (h *Hub) SetWrds() {
+	h.Stts.SetWrds(h.Wrdr)
+	if h.Wrdch != nil {
+		select {
+		case h.Wrdch <- append([]Wrd{}, h.Wrdr.Wrds...):
+		default:
+			log.Printf("ERROR: wrdch full!")
+		}
+	}
+}
+
+// Round - start round i, wrapping around, journal it & swap its words in
+func (h *Hub) Round(i int) {
+	n := len(h.Schd.Rnds)
+	h.Schd.Start(((i % n) + n) % n)
+	h.Jrnl.Config(h.Stns)
+	h.Stts.SetRnd(h.Schd.Stts())
+	h.Repool()
+	h.Qstn()
+}
+
+// Qstn - tell data clients the round question & blnkr the round theme
+func (h *Hub) Qstn() {
+	h.Bcast(h.QstnMsg())
+	if h.Thmch != nil {
+		select {
+		case h.Thmch <- CurThm:
+		default:
+			log.Printf("ERROR: thmch full!")
+		}
+	}
+}
+
+// QstnMsg - message with round question for data clients
+func (h *Hub) QstnMsg() DataMsg {
+	return DataMsg{Flavor: "question", Question: Qstn}
+}
+
+// Bcast - send message to every data client, dropping clients that are full
+func (h *Hub) Bcast(dm DataMsg) {
+	for dest := range h.Dcdx {
+		h.send(dest, dm)
+	}
+}
+
+// Pub - send message to data clients subscribed to topic
+func (h *Hub) Pub(topic string, dm DataMsg) {
+	for dest := range h.Sbs[topic] {
+		h.send(dest, dm)
+	}
+}
+
+// send message to data client, dropping it if it is full or gone
+func (h *Hub) send(dest string, dm DataMsg) {
+	dc, has := h.Dcdx[dest]
+	if !has {
+		for _, sbs := range h.Sbs {
+			delete(sbs, dest)
+		}
+		return
+	}
+	select {
+	case dc.MsgCh <- dm:
+	default:
+		log.Printf("ERROR: msgch for %v full!", dc.Dest)
+		close(dc.MsgCh)
+		delete(h.Dcdx, dest)
+		for _, sbs := range h.Sbs {
+			delete(sbs, dest)
+		}
+	}
+}
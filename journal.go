@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// journal event kinds
+const (
+	JrnlStart  = "start"    // server start with rng seed & config
+	JrnlTeensy = "teensy"   // message from a teensy over udp
+	JrnlWS     = "ws"       // message injected by a websocket data client
+	JrnlCycle  = "cycle"    // word cycle
+	JrnlCmd    = "cmd"      // operator command to blnkr
+	JrnlConfig = "config"   // config change
+	JrnlOvrd   = "override" // schedule override set, or cleared if empty
+)
+
+// JrnlCfg - settings that affect how events play out
+type JrnlCfg struct {
+	Stations  []int              `json:"stations"`
+	PostDelay int64              `json:"post_delay"`
+	StrkThrsh int                `json:"streak_threshold"`
+	WvClr     RGB                `json:"wave_color"`
+	Pool      []Wrd              `json:"pool"`
+	Session   string             `json:"session,omitempty"`
+	Pick      string             `json:"pick,omitempty"`
+	Weights   map[string]float64 `json:"weights,omitempty"`
+	Cats      map[string]string  `json:"categories,omitempty"`
+	SlotDelay int64              `json:"slot_delay,omitempty"`
+	SlotJttr  float64            `json:"slot_jitter,omitempty"`
+	CycleVts  int                `json:"cycle_votes,omitempty"`
+	Question  string             `json:"question,omitempty"`
+	Theme     *Theme             `json:"theme,omitempty"`
+	Power     *PwrCfg            `json:"power,omitempty"`
+	IdleDelay int64              `json:"idle_delay,omitempty"`
+	OutBits   int                `json:"out_bits,omitempty"` // DfltBits if missing
+	Dither    bool               `json:"dither,omitempty"`
+	Layers    []Lyr              `json:"layers,omitempty"` // DfltLyrs if missing
+	Scenes    map[string]*Scene  `json:"scenes,omitempty"`
+	Particles *PrtclCfg          `json:"particles,omitempty"`
+	Schedule  *ShwCfg            `json:"schedule,omitempty"`
+}
+
+// JrnlEvt - json record for every event that changes server state
+type JrnlEvt struct {
+	Time int64      `json:"time"`
+	Kind string     `json:"kind"`
+	Seed int64      `json:"seed,omitempty"`
+	Cfg  *JrnlCfg   `json:"config,omitempty"`
+	Msg  *TeensyMsg `json:"msg,omitempty"`
+	Cmd  string     `json:"cmd,omitempty"`
+	Ovrd *ShwOvrd   `json:"override,omitempty"`
+}
+
+// Jrnl - writes events to json lines journal; nil journal drops events
+// events come from the hub & the schedule handlers
+type Jrnl struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewJrnl - create journal file
+func NewJrnl(fn string) (*Jrnl, error) {
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("journaling events to %v", fn)
+	return &Jrnl{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// write event stamped now, returning the stamp
+func (j *Jrnl) write(ev JrnlEvt) int64 {
+	ev.Time = NowMs()
+	if j == nil {
+		return ev.Time
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(&ev); err != nil {
+		log.Printf("ERROR: failed to journal %v event: %v", ev.Kind, err)
+	}
+	return ev.Time
+}
+
+// CurCfg - current config for journaling
+func CurCfg(stns []int) *JrnlCfg {
+	var pwr *PwrCfg // only with limits so runs without hash as before
+	if Pwr.On() {
+		pwr = &Pwr
+	}
+	bits := 0 // likewise only when not the lamps' own depth
+	if OutBits != DfltBits {
+		bits = OutBits
+	}
+	return &JrnlCfg{
+		Stations:  stns,
+		PostDelay: PostDelay,
+		StrkThrsh: StrkThrsh,
+		WvClr:     WvClr,
+		Pool:      WrdPool,
+		Session:   SssnTag,
+		Pick:      PckMd,
+		Weights:   WrdWghts,
+		Cats:      WrdCats,
+		SlotDelay: SlotDelay,
+		SlotJttr:  SlotJttr,
+		CycleVts:  CycleVts,
+		Question:  Qstn,
+		Theme:     CurThm,
+		Power:     pwr,
+		IdleDelay: IdleDelay,
+		OutBits:   bits,
+		Dither:    Dither,
+		Layers:    CurLyrs,
+		Scenes:    CurScns,
+		Particles: CurPCfg,
+		Schedule:  CurShw,
+	}
+}
+
+// Hash - short stable hash of config to tell runs with different settings apart
+func (cfg *JrnlCfg) Hash() string {
+	c := *cfg
+	c.Session = "" // same settings under another session name hash the same
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Apply - set config values for replay
+func (cfg *JrnlCfg) Apply() {
+	PostDelay = cfg.PostDelay
+	StrkThrsh = cfg.StrkThrsh
+	WvClr = cfg.WvClr
+	if len(cfg.Pool) > 0 {
+		WrdPool = cfg.Pool
+	}
+	SssnTag = cfg.Session
+	PckMd = cfg.Pick
+	WrdWghts = cfg.Weights
+	WrdCats = cfg.Cats
+	SlotDelay = cfg.SlotDelay
+	SlotJttr = cfg.SlotJttr
+	CycleVts = cfg.CycleVts
+	Qstn = cfg.Question
+	CurThm = cfg.Theme
+	IdleDelay = cfg.IdleDelay
+	Pwr = PwrDflt
+	if cfg.Power != nil {
+		Pwr = *cfg.Power
+	}
+	CurLyrs, CurScns, CurPCfg, CurShw = cfg.Layers, cfg.Scenes, cfg.Particles, cfg.Schedule
+	OutBits, Dither = DfltBits, cfg.Dither
+	if cfg.OutBits > 0 {
+		OutBits = cfg.OutBits
+	}
+}
+
+// Start - journal rng seed & config at server start, returning its time
+// which blnkr frames are timed from
+func (j *Jrnl) Start(seed int64, stns []int) int64 {
+	return j.write(JrnlEvt{Kind: JrnlStart, Seed: seed, Cfg: CurCfg(stns)})
+}
+
+// Teensy - journal inbound teensy message
+func (j *Jrnl) Teensy(tm TeensyMsg) {
+	kind := JrnlTeensy
+	if tm.Via == ViaWS {
+		kind = JrnlWS
+	}
+	j.write(JrnlEvt{Kind: kind, Msg: &tm})
+}
+
+// Cycle - journal word cycle
+func (j *Jrnl) Cycle() {
+	j.write(JrnlEvt{Kind: JrnlCycle})
+}
+
+// Cmd - journal operator command
+func (j *Jrnl) Cmd(cmd string) {
+	j.write(JrnlEvt{Kind: JrnlCmd, Cmd: cmd})
+}
+
+// Config - journal config change
+func (j *Jrnl) Config(stns []int) {
+	j.write(JrnlEvt{Kind: JrnlConfig, Cfg: CurCfg(stns)})
+}
+
+// Ovrd - journal schedule override, nil when cleared
+func (j *Jrnl) Ovrd(o *ShwOvrd) {
+	j.write(JrnlEvt{Kind: JrnlOvrd, Ovrd: o})
+}
+
+// Close - close journal file
+func (j *Jrnl) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// ReadJrnl - read journal events, which must begin with a start event
+func ReadJrnl(r io.Reader) ([]JrnlEvt, error) {
+	evs := []JrnlEvt{}
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		var ev JrnlEvt
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("line %v: %v", ln, err)
+		}
+		evs = append(evs, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(evs) == 0 || evs[0].Kind != JrnlStart || evs[0].Cfg == nil {
+		return nil, errors.New("journal does not begin with a start event")
+	}
+	return evs, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LgWrtr - appends json lines to a log file, rotating it by size & day and
+// fsyncing periodically; every write should be one whole record
+type LgWrtr struct {
+	Fn      string        // current log file, rotated files are renamed beside it
+	MaxSize int64         // rotate once file reaches this many bytes, 0 never
+	Daily   bool          // rotate when the local date changes
+	Hdr     []byte        // written at the top of every rotated in file
+	SyncDly time.Duration // max time between fsyncs of written data
+
+	mu    sync.Mutex
+	f     *os.File
+	size  int64
+	day   string
+	dirty bool
+	done  chan bool
+}
+
+// NewLgWrtr - open log file for appending & start periodic fsync
+func NewLgWrtr(fn string, maxsize int64, daily bool, syncdly time.Duration) (*LgWrtr, error) {
+	lw := LgWrtr{
+		Fn:      fn,
+		MaxSize: maxsize,
+		Daily:   daily,
+		SyncDly: syncdly,
+		done:    make(chan bool),
+	}
+	if err := lw.open(); err != nil {
+		return nil, err
+	}
+	if syncdly > 0 {
+		go lw.syncLoop()
+	}
+	return &lw, nil
+}
+
+// open current log file & note its size & day
+func (lw *LgWrtr) open() error {
+	f, err := os.OpenFile(lw.Fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	lw.f = f
+	lw.size = fi.Size()
+	lw.day = lwDay(NowMs())
+	return nil
+}
+
+// local date of unix ms stamp
+func lwDay(ms int64) string {
+	return time.Unix(0, ms*1000000).Format("20060102")
+}
+
+// rotate current file out of the way & start a fresh one with header
+func (lw *LgWrtr) rotate() error {
+	if err := lw.f.Sync(); err != nil {
+		log.Printf("ERROR: failed to sync %v before rotating: %v", lw.Fn, err)
+	}
+	if err := lw.f.Close(); err != nil {
+		return err
+	}
+	lw.f = nil
+
+	// name rotated file after the time it was closed
+	ext := filepath.Ext(lw.Fn)
+	base := strings.TrimSuffix(lw.Fn, ext)
+	stmp := time.Unix(0, NowMs()*1000000).Format("20060102-150405")
+	rfn := fmt.Sprintf("%v-%v%v", base, stmp, ext)
+	for i := 1; fileExists(rfn); i++ {
+		rfn = fmt.Sprintf("%v-%v-%v%v", base, stmp, i, ext)
+	}
+	if err := os.Rename(lw.Fn, rfn); err != nil {
+		if oerr := lw.open(); oerr != nil { // keep appending to the old file
+			return oerr
+		}
+		return err
+	}
+	log.Printf("rotated %v to %v", lw.Fn, rfn)
+
+	if err := lw.open(); err != nil {
+		return err
+	}
+	if len(lw.Hdr) > 0 {
+		n, err := lw.f.Write(lw.Hdr)
+		lw.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+func fileExists(fn string) bool {
+	_, err := os.Stat(fn)
+	return err == nil
+}
+
+// Write - append record, rotating first if it is due
+func (lw *LgWrtr) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	full := lw.MaxSize > 0 && lw.size > 0 && lw.size+int64(len(p)) > lw.MaxSize
+	nwday := lw.Daily && lwDay(NowMs()) != lw.day
+	if full || nwday {
+		if err := lw.rotate(); err != nil {
+			log.Printf("ERROR: failed to rotate %v: %v", lw.Fn, err)
+			if lw.f == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := lw.f.Write(p)
+	lw.size += int64(n)
+	lw.dirty = true
+	return n, err
+}
+
+// Sync - fsync written data
+func (lw *LgWrtr) Sync() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if !lw.dirty || lw.f == nil {
+		return nil
+	}
+	lw.dirty = false
+	return lw.f.Sync()
+}
+
+// fsync at intervals until closed
+func (lw *LgWrtr) syncLoop() {
+	tck := time.NewTicker(lw.SyncDly)
+	defer tck.Stop()
+	for {
+		select {
+		case <-tck.C:
+			if err := lw.Sync(); err != nil {
+				log.Printf("ERROR: failed to sync %v: %v", lw.Fn, err)
+			}
+		case <-lw.done:
+			return
+		}
+	}
+}
+
+// Close - stop periodic fsync, sync & close file
+func (lw *LgWrtr) Close() error {
+	close(lw.done)
+	if err := lw.Sync(); err != nil {
+		log.Printf("ERROR: failed to sync %v: %v", lw.Fn, err)
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.f == nil {
+		return nil
+	}
+	return lw.f.Close()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// MtchTopic - data client topic for live ranking messages
+const MtchTopic = "ranking"
+
+// elo scoring constants
+const (
+	EloStrt = 1500.0 // rating of a word before its first matchup
+	EloK    = 32.0   // max rating change per matchup
+)
+
+// MtchEntry - rating & record of one word in the ranking
+type MtchEntry struct {
+	Word   string  `json:"word"`
+	Elo    float64 `json:"elo"`
+	Wins   int     `json:"wins"`
+	Games  int     `json:"games"`
+	Posted int     `json:"posted"` // times the word was put in a matchup
+}
+
+// Mtchr - pick strategy posting balanced word pairs per station, keeps elo
+// ratings from votes
+type Mtchr struct {
+	mu     sync.Mutex
+	Elo    map[string]float64
+	Wins   map[string]int
+	Gms    map[string]int
+	Pstd   map[string]int
+	PrCnts map[[2]string]int // times each pair was posted, by sorted words
+	pool   []Wrd             // words to rank
+}
+
+// NewMtchr - init empty ratings
+func NewMtchr() *Mtchr {
+	return &Mtchr{
+		Elo:    make(map[string]float64),
+		Wins:   make(map[string]int),
+		Gms:    make(map[string]int),
+		Pstd:   make(map[string]int),
+		PrCnts: make(map[[2]string]int),
+	}
+}
+
+// sorted key for pair of words
+func pairKey(a string, b string) [2]string {
+	if b < a {
+		return [2]string{b, a}
+	}
+	return [2]string{a, b}
+}
+
+// Pairs - both sides of a station are posted together
+func (m *Mtchr) Pairs() bool { return true }
+
+// Slots - both sides of a random station
+func (m *Mtchr) Slots(w Wrdr) []int {
+	wrddx := w.Rnd.Intn(len(w.Wrds))
+	wrddx -= wrddx % 2 // left slot of station
+	return []int{wrddx, wrddx + 1}
+}
+
+// Pick - least posted pair of candidates
+func (m *Mtchr) Pick(w Wrdr, cands []Wrd, n int) []Wrd {
+	a, b, ok := m.Pair(cands, w.Rnd.Intn)
+	if !ok {
+		return cands // fewer than two left
+	}
+	wrds := []Wrd{a, b}
+	if n < len(wrds) {
+		wrds = wrds[:n]
+	}
+	return wrds
+}
+
+// Posted - pairs are counted when picked
+func (m *Mtchr) Posted(wrddx int, old Wrd, from int64, to int64) {}
+
+// Pair - pick the least posted pair of free words, ties at random & in
+// random order; ok is false if fewer than two words are free
+func (m *Mtchr) Pair(free []Wrd, pck func(int) int) (Wrd, Wrd, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// gather pairs shown least so far, then least posted words within those
+	var best [][2]int
+	bcnt, bpst := -1, -1
+	for i := 0; i < len(free); i++ {
+		for j := i + 1; j < len(free); j++ {
+			cnt := m.PrCnts[pairKey(free[i].Str, free[j].Str)]
+			pst := m.Pstd[free[i].Str] + m.Pstd[free[j].Str]
+			if bcnt < 0 || cnt < bcnt || (cnt == bcnt && pst < bpst) {
+				best = best[:0]
+				bcnt, bpst = cnt, pst
+			}
+			if cnt == bcnt && pst == bpst {
+				best = append(best, [2]int{i, j})
+			}
+		}
+	}
+	if len(best) == 0 {
+		return Wrd{}, Wrd{}, false
+	}
+
+	p := best[pck(len(best))]
+	a, b := free[p[0]], free[p[1]]
+	if pck(2) == 1 {
+		a, b = b, a
+	}
+	m.PrCnts[pairKey(a.Str, b.Str)]++
+	m.Pstd[a.Str]++
+	m.Pstd[b.Str]++
+	return a, b, true
+}
+
+// Result - score a vote for wnr over lsr
+func (m *Mtchr) Result(wnr string, lsr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rw, rl := m.rating(wnr), m.rating(lsr)
+	exp := 1 / (1 + math.Pow(10, (rl-rw)/400)) // expected score of winner
+	m.Elo[wnr] = rw + EloK*(1-exp)
+	m.Elo[lsr] = rl - EloK*(1-exp)
+	m.Wins[wnr]++
+	m.Gms[wnr]++
+	m.Gms[lsr]++
+}
+
+// Seed - score earlier results in order, as winner & loser pairs
+func (m *Mtchr) Seed(rs [][2]string) {
+	for _, r := range rs {
+		m.Result(r[0], r[1])
+	}
+}
+
+func (m *Mtchr) rating(wrd string) float64 {
+	if r, has := m.Elo[wrd]; has {
+		return r
+	}
+	return EloStrt
+}
+
+// SetPool - set words to rank
+func (m *Mtchr) SetPool(pool []Wrd) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool = pool
+}
+
+// Rank - every pool word ordered by rating, unrated words at the start rating
+func (m *Mtchr) Rank() []MtchEntry {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	es := []MtchEntry{}
+	add := func(wrd string) {
+		if seen[wrd] {
+			return
+		}
+		seen[wrd] = true
+		es = append(es, MtchEntry{wrd, m.rating(wrd), m.Wins[wrd], m.Gms[wrd], m.Pstd[wrd]})
+	}
+	for _, wrd := range m.pool {
+		add(wrd.Str)
+	}
+	for wrd := range m.Elo { // words since dropped from the pool
+		add(wrd)
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Elo != es[j].Elo {
+			return es[i].Elo > es[j].Elo
+		}
+		return es[i].Word < es[j].Word
+	})
+	return es
+}
+
+// MtchHandlers - register ranking endpoint on default mux: /api/ranking
+func MtchHandlers(m *Mtchr) {
+	http.HandleFunc("/api/ranking", func(w http.ResponseWriter, r *http.Request) {
+		es := m.Rank()
+		if es == nil {
+			es = []MtchEntry{}
+		}
+		writeJSON(w, es, nil)
+	})
+}
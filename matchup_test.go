@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// store keeping records in memory
+type memStr struct{ lgs []WrdLg }
+
+func (ms *memStr) Log(lg WrdLg) error             { ms.lgs = append(ms.lgs, lg); return nil }
+func (ms *memStr) Beat(src int, stmp int64) error { return nil }
+func (ms *memStr) Close() error                   { return nil }
+
+func TestLogTouchScores(t *testing.T) {
+	defer func(md string, p []Wrd) { PckMd, WrdPool = md, p }(PckMd, WrdPool)
+	defer func(c func() int64) { clck = c }(clck)
+	now := int64(1000)
+	clck = func() int64 { return now }
+	PckMd = PckMatchup
+	WrdPool = []Wrd{{Str: "a"}, {Str: "b"}}
+
+	tests := []struct {
+		name  string
+		tchs  []string // flavor of each touch on the left, 100ms apart
+		games int
+	}{
+		{"held touch", []string{"start_touch", "", "", "end_touch"}, 1},
+		{"end without start", []string{"end_touch"}, 0},
+		{"stutter waits for real end", []string{"start_touch", "end_touch", "", "", "end_touch"}, 1},
+		{"only stutter", []string{"start_touch", "end_touch"}, 0},
+		{"second end is orphan", []string{"start_touch", "", "", "end_touch", "end_touch"}, 1},
+	}
+	for _, tt := range tests {
+		ms := &memStr{}
+		w := NewWrdr([]int{101}, ms, rand.New(rand.NewSource(1)))
+		for _, flvr := range tt.tchs {
+			now += 100
+			if flvr != "" {
+				w.LogTouch(101, flvr, "left")
+			}
+		}
+		gms, opps := 0, 0
+		for _, e := range w.Mtchr.Rank() {
+			gms += e.Games
+		}
+		for _, lg := range ms.lgs {
+			if lg.Opponent != "" {
+				opps++
+			}
+		}
+		if gms != 2*tt.games || opps != tt.games {
+			t.Errorf("%v: %v games, %v records with opponents, want %v", tt.name, gms/2, opps, tt.games)
+		}
+	}
+}
+
+func TestMtchrSeed(t *testing.T) {
+	m, n := NewMtchr(), NewMtchr()
+	rs := [][2]string{{"a", "b"}, {"a", "c"}, {"c", "b"}}
+	for _, r := range rs {
+		m.Result(r[0], r[1])
+	}
+	n.Seed(rs)
+	for _, w := range []string{"a", "b", "c"} {
+		if m.Elo[w] != n.Elo[w] || m.Wins[w] != n.Wins[w] || m.Gms[w] != n.Gms[w] {
+			t.Errorf("%v: seeded %v %v/%v, scored %v %v/%v", w, n.Elo[w], n.Wins[w], n.Gms[w], m.Elo[w], m.Wins[w], m.Gms[w])
+		}
+	}
+}
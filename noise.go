@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// NoiseLUT - # of palette colors precomputed between stops
+const NoiseLUT = 256
+
+// NoiseCfg - ambient effect coloring each led from 3d perlin noise at its
+// position, drifting over time, mapped onto a palette
+//
+//	{"scale": 0.01, "speed": 20, "palette": ["#001030", "#0060a0", "#40d0c0"],
+//	 "brightness": 0.4, "octaves": 2}
+type NoiseCfg struct {
+	Scale   float64 `json:"scale"`             // noise cycles per layout unit
+	Speed   float64 `json:"speed"`             // layout units the field drifts per second
+	Palette []RGB   `json:"palette"`           // stops from low to high noise
+	Brt     float64 `json:"brightness"`        // 0-1
+	Octaves int     `json:"octaves,omitempty"` // finer detail layered on, 1 if 0
+	Seed    int64   `json:"seed,omitempty"`
+
+	perm []int
+	lut  []FRGB
+}
+
+// check - make sure noise parameters are usable
+func (nc *NoiseCfg) check() error {
+	if nc.Scale <= 0 {
+		return errors.New("noise scale must be positive")
+	}
+	if len(nc.Palette) == 0 {
+		return errors.New("noise needs a palette")
+	}
+	if nc.Brt < 0 || nc.Brt > 1 {
+		return errors.New("noise brightness is not between 0 & 1")
+	}
+	if nc.Octaves < 0 || nc.Octaves > 6 {
+		return errors.New("noise octaves must be 0 to 6, 0 means 1")
+	}
+	return nil
+}
+
+// build permutation table from seed & palette lookup table through oklab
+func (nc *NoiseCfg) init() {
+	p := rand.New(rand.NewSource(nc.Seed)).Perm(256)
+	nc.perm = append(p, p...)
+
+	nc.lut = make([]FRGB, NoiseLUT)
+	n := len(nc.Palette) - 1
+	for i := range nc.lut {
+		if n == 0 {
+			nc.lut[i] = nc.Palette[0].ToF()
+			continue
+		}
+		v := float64(i) / (NoiseLUT - 1) * float64(n)
+		s := int(v)
+		if s >= n {
+			s = n - 1
+		}
+		nc.lut[i] = nc.Palette[s].Lerp(nc.Palette[s+1], v-float64(s)).ToF()
+	}
+}
+
+// At - palette color of noise at led position at time now ms
+func (nc *NoiseCfg) At(crds mgl64.Vec3, now int64) FRGB {
+	if nc.perm == nil {
+		nc.init()
+	}
+	t := float64(now) / 1000 * nc.Speed * nc.Scale
+	octs := nc.Octaves
+	if octs == 0 {
+		octs = 1
+	}
+
+	// each octave drifts its own way so the field churns instead of sliding
+	v, amp, frq, sum := 0.0, 1.0, nc.Scale, 0.0
+	for o := 0; o < octs; o++ {
+		dir := 1.0
+		if o%2 == 1 {
+			dir = -1
+		}
+		v += amp * nc.perlin(crds[0]*frq+dir*t, crds[1]*frq+0.7*t, crds[2]*frq-dir*0.4*t+float64(o)*17.3)
+		sum += amp
+		amp /= 2
+		frq *= 2
+	}
+	v = (v/sum + 1) / 2 // -1..1 to 0..1
+	i := int(v * (NoiseLUT - 1))
+	if i < 0 {
+		i = 0
+	} else if i >= NoiseLUT {
+		i = NoiseLUT - 1
+	}
+	return nc.lut[i].Dim(nc.Brt)
+}
+
+// improved perlin noise, about -1..1
+func (nc *NoiseCfg) perlin(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := nc.perm
+	a := p[X] + Y
+	aa, ab := p[a]+Z, p[a+1]+Z
+	b := p[X+1] + Y
+	ba, bb := p[b]+Z, p[b+1]+Z
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+func fade(t float64) float64 { return t * t * t * (t*(t*6-15) + 10) }
+
+func lerp(t, a, b float64) float64 { return a + t*(b-a) }
+
+// dot of distance vector with one of 12 cube edge gradients picked by hash
+func grad(hsh int, x, y, z float64) float64 {
+	h := hsh & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
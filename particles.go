@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// particle paths from the voting station
+const (
+	PthCenter = "center" // straight to the middle of the layout
+	PthMatch  = "match"  // straight to the lamp showing the closest color
+	PthSpline = "spline" // curving through random waypoints to a random lamp
+)
+
+// PrtclPts - most particle trail points in flight, oldest particles are
+// dropped past it so lighting them stays well inside a frame
+const PrtclPts = 600
+
+// PrtclReach - particles light leds within this many radii, their falloff
+// is near 1% there
+const PrtclReach = 3.0
+
+// PrtclCfg - particles votes emit from their station, colored in the word
+// color, lighting leds near them & their trails
+//
+//	{"path": "spline", "count": 3, "duration": 3000, "radius": 25,
+//	 "trail": 12, "spread": 15}
+type PrtclCfg struct {
+	Path   string  `json:"path"`
+	Count  int     `json:"count"`    // particles per vote
+	Dur    int64   `json:"duration"` // ms to reach target
+	Radius float64 `json:"radius"`   // falloff of light around a particle in layout units
+	Trail  int     `json:"trail"`    // # of past positions lit behind a particle
+	Spread float64 `json:"spread"`   // random offset of particle paths in layout units
+	Seed   int64   `json:"seed"`
+
+	rnd *rand.Rand
+	lns []float64 // natural log of 0..Trail, for trail point weights
+}
+
+// Prtcl - particle flying along its path, with the positions it left behind
+type Prtcl struct {
+	Pts  []mgl64.Vec3 // control points of path
+	Strt int64
+	Clr  FRGB
+	Trl  []mgl64.Vec3 // recent positions, newest last
+
+	mn, mx mgl64.Vec3 // bounds of trail grown by reach, leds outside stay unlit
+}
+
+// ReadPrtcls - read & check particle settings
+func ReadPrtcls(fn string) (*PrtclCfg, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	pc := PrtclCfg{Path: PthCenter, Count: 3, Dur: 3000, Radius: 25, Trail: 12}
+	if err := json.Unmarshal(b, &pc); err != nil {
+		return nil, err
+	}
+	switch pc.Path {
+	case PthCenter, PthMatch, PthSpline:
+	default:
+		return nil, fmt.Errorf("unknown particle path '%v'", pc.Path)
+	}
+	if pc.Count < 1 || pc.Dur <= 0 || pc.Radius <= 0 || pc.Trail < 1 || pc.Spread < 0 {
+		return nil, errors.New("particle count, duration, radius & trail must be positive & spread not negative")
+	}
+	pc.rnd = rand.New(rand.NewSource(pc.Seed))
+	return &pc, nil
+}
+
+// PrtclLyr - layer added over the others when particles are set but no layer shows them
+var PrtclLyr = Lyr{Name: "particles", Mode: BlndAdd, Opacity: 1, Prtcls: true}
+
+// CurPCfg - particle settings in use incl their seed, journaled so replays
+// send particles along the same paths
+var CurPCfg *PrtclCfg
+
+// LoadPrtcls - read particle settings & make sure a layer shows them, none if fn is empty
+func (blnkr *Blnkr) LoadPrtcls(fn string) error {
+	if fn == "" {
+		blnkr.SetPrtcls(nil)
+		return nil
+	}
+	pc, err := ReadPrtcls(fn)
+	if err != nil {
+		return err
+	}
+	blnkr.SetPrtcls(pc)
+	return nil
+}
+
+// SetPrtcls - use particle settings, nil for none, seeding their paths &
+// adding a layer over the others if none shows them
+func (blnkr *Blnkr) SetPrtcls(pc *PrtclCfg) {
+	blnkr.PCfg, CurPCfg = pc, pc
+	if pc == nil {
+		return
+	}
+	if pc.rnd == nil {
+		pc.rnd = rand.New(rand.NewSource(pc.Seed))
+	}
+	if !hasPrtcls(blnkr.Lyrs) {
+		log.Printf("no layer shows particles, adding one over the others")
+		blnkr.Lyrs = append(append([]Lyr{}, blnkr.Lyrs...), PrtclLyr)
+		CurLyrs = blnkr.Lyrs
+	}
+	for _, nm := range blnkr.ScnNames() {
+		if sc := blnkr.Scns[nm]; len(sc.Lyrs) > 0 && !hasPrtcls(sc.Lyrs) {
+			log.Printf("WARNING: no layer of scene %v shows particles", nm)
+		}
+	}
+}
+
+// any layer showing particles
+func hasPrtcls(lyrs []Lyr) bool {
+	for _, ly := range lyrs {
+		if ly.Prtcls {
+			return true
+		}
+	}
+	return false
+}
+
+// random offset within spread
+func (pc *PrtclCfg) jttr() mgl64.Vec3 {
+	return mgl64.Vec3{
+		(2*pc.rnd.Float64() - 1) * pc.Spread,
+		(2*pc.rnd.Float64() - 1) * pc.Spread,
+		(2*pc.rnd.Float64() - 1) * pc.Spread,
+	}
+}
+
+// emit - send particles in vote color from station epicenter along paths
+func (blnkr *Blnkr) emit(edx int, clr RGB) {
+	pc := blnkr.PCfg
+	if pc == nil || edx >= len(blnkr.Epcntrs) || len(blnkr.Epcntrs[edx]) == 0 {
+		return
+	}
+	from := blnkr.Epcntrs[edx][0]
+	mn, mx := blnkr.bounds()
+	ctr := mn.Add(mx).Mul(0.5)
+
+	for n := 0; n < pc.Count; n++ {
+		var pts []mgl64.Vec3
+		switch pc.Path {
+		case PthCenter:
+			pts = []mgl64.Vec3{from, ctr.Add(pc.jttr())}
+		case PthMatch:
+			pts = []mgl64.Vec3{from, blnkr.match(clr).Add(pc.jttr())}
+		case PthSpline:
+			pts = []mgl64.Vec3{from}
+			for w := 0; w < 2; w++ {
+				pts = append(pts, mgl64.Vec3{
+					mn[0] + pc.rnd.Float64()*(mx[0]-mn[0]),
+					mn[1] + pc.rnd.Float64()*(mx[1]-mn[1]),
+					mn[2] + pc.rnd.Float64()*(mx[2]-mn[2]),
+				})
+			}
+			to := ctr
+			if ips := blnkr.IPs; len(ips) > 0 {
+				to = blnkr.lmpCtr(ips[pc.rnd.Intn(len(ips))])
+			}
+			pts = append(pts, to.Add(pc.jttr()))
+		}
+		blnkr.Prtcls = append(blnkr.Prtcls, &Prtcl{Pts: pts, Strt: NowMs(), Clr: clr.ToF()})
+	}
+	if over := len(blnkr.Prtcls) - PrtclPts/pc.Trail; over > 0 {
+		blnkr.Prtcls = blnkr.Prtcls[over:]
+	}
+}
+
+// bounds of led coordinates
+func (blnkr *Blnkr) bounds() (mgl64.Vec3, mgl64.Vec3) {
+	mn := mgl64.Vec3{math.Inf(1), math.Inf(1), math.Inf(1)}
+	mx := mgl64.Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, led := range blnkr.Leds {
+		c := mgl64.Vec3{led.X, led.Y, led.Z}
+		for a := 0; a < 3; a++ {
+			mn[a] = math.Min(mn[a], c[a])
+			mx[a] = math.Max(mx[a], c[a])
+		}
+	}
+	return mn, mx
+}
+
+// mean position of lamp leds
+func (blnkr *Blnkr) lmpCtr(ip string) mgl64.Vec3 {
+	c := mgl64.Vec3{}
+	n := 0.0
+	for _, led := range blnkr.Leds {
+		if led.IP == ip {
+			c = c.Add(mgl64.Vec3{led.X, led.Y, led.Z})
+			n++
+		}
+	}
+	if n > 0 {
+		c = c.Mul(1 / n)
+	}
+	return c
+}
+
+// center of lamp whose mean color is closest to clr in oklab
+func (blnkr *Blnkr) match(clr RGB) mgl64.Vec3 {
+	want := clr.OKLab()
+	best, bdst := "", math.Inf(1)
+	for _, ip := range blnkr.IPs {
+		avg := FRGB{}
+		for _, f := range blnkr.Lmps[ip].Flts() {
+			for c := 0; c < 3; c++ {
+				avg[c] += f[c] / LampSize
+			}
+		}
+		lab := avg.RGB().OKLab()
+		dst := math.Pow(lab.L-want.L, 2) + math.Pow(lab.A-want.A, 2) + math.Pow(lab.B-want.B, 2)
+		if dst < bdst {
+			best, bdst = ip, dst
+		}
+	}
+	return blnkr.lmpCtr(best)
+}
+
+// At - particle position t of the way along its path, catmull-rom through
+// control points so splines pass through every waypoint
+func (p *Prtcl) At(t float64) mgl64.Vec3 {
+	n := len(p.Pts) - 1
+	if t >= 1 {
+		return p.Pts[n]
+	}
+	seg := t * float64(n)
+	i := int(seg)
+	u := seg - float64(i)
+	pt := func(j int) mgl64.Vec3 {
+		if j < 0 {
+			j = 0
+		} else if j > n {
+			j = n
+		}
+		return p.Pts[j]
+	}
+	p0, p1, p2, p3 := pt(i-1), pt(i), pt(i+1), pt(i+2)
+	u2, u3 := u*u, u*u*u
+	return p0.Mul(-0.5*u3 + u2 - 0.5*u).
+		Add(p1.Mul(1.5*u3 - 2.5*u2 + 1)).
+		Add(p2.Mul(-1.5*u3 + 2*u2 + 0.5*u)).
+		Add(p3.Mul(0.5*u3 - 0.5*u2))
+}
+
+// move particles along their paths, drop those whose trail has run out &
+// light leds once a frame for every layer & scene showing them
+func (blnkr *Blnkr) updatePrtcls() {
+	pc := blnkr.PCfg
+	if pc == nil {
+		return
+	}
+	now := NowMs()
+	live := blnkr.Prtcls[:0]
+	for _, p := range blnkr.Prtcls {
+		t := float64(now-p.Strt) / float64(pc.Dur)
+		if t < 1 {
+			p.Trl = append(p.Trl, p.At(t))
+		} else if len(p.Trl) > 0 {
+			p.Trl = p.Trl[1:] // arrived, let the trail catch up
+		}
+		if len(p.Trl) > pc.Trail {
+			p.Trl = p.Trl[len(p.Trl)-pc.Trail:]
+		}
+		if t < 1 || len(p.Trl) > 0 {
+			p.bound(pc.Radius * PrtclReach)
+			live = append(live, p)
+		}
+	}
+	blnkr.Prtcls = live
+
+	for _, lmp := range blnkr.Lmps {
+		for i := range lmp.Pnts {
+			lmp.Pnts[i].Prt = blnkr.prtclClr(lmp.Pnts[i].Crds)
+		}
+	}
+}
+
+// set bounds of trail grown by reach
+func (p *Prtcl) bound(reach float64) {
+	p.mn = mgl64.Vec3{math.Inf(1), math.Inf(1), math.Inf(1)}
+	p.mx = mgl64.Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, pos := range p.Trl {
+		for a := 0; a < 3; a++ {
+			p.mn[a] = math.Min(p.mn[a], pos[a]-reach)
+			p.mx[a] = math.Max(p.mx[a], pos[a]+reach)
+		}
+	}
+}
+
+// color particles & trails put on led at crds, fading along each trail &
+// with distance; the brightest point of a trail counts so overlapping trail
+// points dont wash the color out
+func (blnkr *Blnkr) prtclClr(crds mgl64.Vec3) FRGB {
+	f := FRGB{}
+	pc := blnkr.PCfg
+	if pc == nil {
+		return f
+	}
+	if len(pc.lns) <= pc.Trail {
+		pc.lns = make([]float64, pc.Trail+1)
+		for i := range pc.lns {
+			pc.lns[i] = math.Log(float64(i))
+		}
+	}
+	r2 := 2 * pc.Radius * pc.Radius
+	reach2 := math.Pow(pc.Radius*PrtclReach, 2)
+	for _, p := range blnkr.Prtcls {
+		n := len(p.Trl)
+		if n == 0 || n >= len(pc.lns) ||
+			crds[0] < p.mn[0] || crds[0] > p.mx[0] || crds[1] < p.mn[1] || crds[1] > p.mx[1] ||
+			crds[2] < p.mn[2] || crds[2] > p.mx[2] {
+			continue
+		}
+
+		// brightest point compared by log so there is one exp per particle
+		// & spelled out as this runs for every led & trail point each frame
+		lb := math.Inf(-1)
+		for k := range p.Trl {
+			dx, dy, dz := crds[0]-p.Trl[k][0], crds[1]-p.Trl[k][1], crds[2]-p.Trl[k][2]
+			if d2 := dx*dx + dy*dy + dz*dz; d2 < reach2 {
+				if l := pc.lns[k+1] - d2/r2; l > lb {
+					lb = l
+				}
+			}
+		}
+		b := math.Exp(lb - pc.lns[n])
+		if b > 0.001 {
+			f = f.Add(p.Clr.Dim(b))
+		}
+	}
+	return f
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// brightness particle puts on led at crds without culling
+func prtclB(p *Prtcl, crds mgl64.Vec3, radius float64) float64 {
+	b := 0.0
+	for k, pos := range p.Trl {
+		d := crds.Sub(pos)
+		b = math.Max(b, math.Exp(-d.Dot(d)/(2*radius*radius))*float64(k+1)/float64(len(p.Trl)))
+	}
+	return b
+}
+
+func TestPrtclClrCull(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pc := &PrtclCfg{Radius: 25, Trail: 12}
+	blnkr := &Blnkr{PCfg: pc}
+	cut := math.Exp(-PrtclReach * PrtclReach / 2) // brightest a culled point could be
+	pt := func(s float64) mgl64.Vec3 {
+		return mgl64.Vec3{rnd.Float64() * s, rnd.Float64() * s, rnd.Float64() * s}
+	}
+
+	for i := 0; i < 200; i++ {
+		p := &Prtcl{Clr: FRGB{1, 1, 1}}
+		for k := 0; k < 1+rnd.Intn(pc.Trail); k++ {
+			p.Trl = append(p.Trl, pt(300))
+		}
+		p.bound(pc.Radius * PrtclReach)
+		blnkr.Prtcls = []*Prtcl{p}
+		for j := 0; j < 50; j++ {
+			crds := pt(300)
+			want := prtclB(p, crds, pc.Radius)
+			got := blnkr.prtclClr(crds)[0]
+			if want <= 0.001 {
+				want = 0 // too faint to show
+			}
+			if want > cut && math.Abs(got-want) > 1e-12 || want <= cut && (got > want+1e-12) {
+				t.Fatalf("led %v: got %v, want %v", crds, got, want)
+			}
+		}
+	}
+}
+
+func TestUpdatePrtcls(t *testing.T) {
+	defer func(c func() int64) { clck = c }(clck)
+	now := int64(0)
+	clck = func() int64 { return now }
+
+	pc := &PrtclCfg{Path: PthSpline, Count: 3, Dur: 100, Radius: 10, Trail: 4, rnd: rand.New(rand.NewSource(1))}
+	lmp := &Lmp{IP: "a"}
+	lmp.Pnts[0].Crds = mgl64.Vec3{50, 0, 0}
+	blnkr := &Blnkr{
+		PCfg:    pc,
+		Epcntrs: [][]mgl64.Vec3{{{0, 0, 0}}},
+		Leds:    []Led{{IP: "a", X: 50}, {IP: "a", Index: 1, X: 100, Y: 20}},
+		Lmps:    map[string]*Lmp{"a": lmp},
+	}
+
+	// no lamps to aim splines at falls back to the middle
+	blnkr.emit(0, RGB{0xfff, 0, 0})
+	if len(blnkr.Prtcls) != 3 || blnkr.Prtcls[0].Pts[3] != (mgl64.Vec3{75, 10, 0}) {
+		t.Fatalf("emitted %v", blnkr.Prtcls)
+	}
+
+	// lit along the way, then gone once the trail catches up
+	lit := false
+	for f := 0; f < 10; f++ {
+		now += 33
+		blnkr.updatePrtcls()
+		lit = lit || lmp.Pnts[0].Prt[0] > 0
+		if lmp.Pnts[0].Prt[1] != 0 {
+			t.Fatalf("frame %v: red particles lit %v", f, lmp.Pnts[0].Prt)
+		}
+	}
+	if !lit || len(blnkr.Prtcls) != 0 || lmp.Pnts[0].Prt != (FRGB{}) {
+		t.Errorf("lit %v, %v particles left, led at %v", lit, len(blnkr.Prtcls), lmp.Pnts[0].Prt)
+	}
+
+	// oldest dropped past the trail point budget
+	for v := 0; v < PrtclPts; v++ {
+		blnkr.emit(0, RGB{0xfff, 0, 0})
+	}
+	if len(blnkr.Prtcls) != PrtclPts/pc.Trail {
+		t.Errorf("%v particles in flight, want %v", len(blnkr.Prtcls), PrtclPts/pc.Trail)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// word pick modes
+const (
+	PckRandom   = "random"   // random slot & word, as always
+	PckFair     = "fair"     // slot up longest & word shown least
+	PckWeighted = "weighted" // random slot & word drawn by weight
+	PckMatchup  = "matchup"  // balanced pairs per station, rated by votes
+)
+
+// PckMd - how wrdr picks slots to cycle & words to post
+var PckMd = PckRandom
+
+// WrdWghts - relative weight per word for weighted picks, 1 if missing
+var WrdWghts = map[string]float64{}
+
+// Pckr - strategy for which slots to cycle & which words to post in them;
+// wrdr hands it only candidates that keep both sides of a station apart
+type Pckr interface {
+	Pairs() bool                                     // post both sides of a station together
+	Slots(w Wrdr) []int                              // slots to cycle next
+	Pick(w Wrdr, cands []Wrd, n int) []Wrd           // up to n distinct words from candidates
+	Posted(wrddx int, old Wrd, from int64, to int64) // old word was up in slot from, to
+}
+
+// NewPckr - strategy for pick mode
+func NewPckr(md string) (Pckr, error) {
+	switch md {
+	case "", PckRandom:
+		return RndPckr{}, nil
+	case PckFair:
+		return &FairPckr{Expsr: make(map[string]int64)}, nil
+	case PckWeighted:
+		return WghtPckr{}, nil
+	case PckMatchup:
+		return NewMtchr(), nil
+	}
+	return nil, fmt.Errorf("unknown pick mode '%v'", md)
+}
+
+// ParseWghts - parse word weights like "Curious=2,Present=0.5"
+func ParseWghts(s string) (map[string]float64, error) {
+	wghts := make(map[string]float64)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		i := strings.LastIndex(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("weight '%v' is not word=weight", kv)
+		}
+		wght, err := strconv.ParseFloat(strings.TrimSpace(kv[i+1:]), 64)
+		if err != nil || wght < 0 {
+			return nil, fmt.Errorf("bad weight for '%v'", kv[:i])
+		}
+		wghts[strings.TrimSpace(kv[:i])] = wght
+	}
+	return wghts, nil
+}
+
+// wrdWght - weight of word, 1 unless set
+func wrdWght(wrd string) float64 {
+	if wght, has := WrdWghts[wrd]; has {
+		return wght
+	}
+	return 1
+}
+
+// RndPckr - cycles a random slot to a random word
+type RndPckr struct{}
+
+// Pairs - slots cycle one at a time
+func (RndPckr) Pairs() bool { return false }
+
+// Slots - any slot at random
+func (RndPckr) Slots(w Wrdr) []int {
+	return []int{w.Rnd.Intn(len(w.Wrds))}
+}
+
+// Pick - draw from whole pool until a candidate comes up, so seeded runs
+// pick the same words they always have
+func (RndPckr) Pick(w Wrdr, cands []Wrd, n int) []Wrd {
+	free := make(map[string]bool)
+	for _, wrd := range cands {
+		free[wrd.Str] = true
+	}
+	wrds := []Wrd{}
+	for len(wrds) < n && len(free) > 0 {
+		nwwrd := WrdPool[w.Rnd.Intn(len(WrdPool))]
+		if free[nwwrd.Str] {
+			wrds = append(wrds, nwwrd)
+			delete(free, nwwrd.Str)
+		}
+	}
+	return wrds
+}
+
+// Posted - random picks keep no history
+func (RndPckr) Posted(wrddx int, old Wrd, from int64, to int64) {}
+
+// FairPckr - evens out time on display per word & per slot
+type FairPckr struct {
+	Expsr map[string]int64 // ms each word was up, not counting current posts
+}
+
+// Pairs - slots cycle one at a time
+func (fp *FairPckr) Pairs() bool { return false }
+
+// Slots - slot whose word has been up longest
+func (fp *FairPckr) Slots(w Wrdr) []int {
+	wrddx := 0
+	for i, stmp := range w.Stmps {
+		if stmp < w.Stmps[wrddx] {
+			wrddx = i
+		}
+	}
+	return []int{wrddx}
+}
+
+// Pick - candidates shown least so far, ties at random
+func (fp *FairPckr) Pick(w Wrdr, cands []Wrd, n int) []Wrd {
+	wrds := []Wrd{}
+	for _, i := range w.Rnd.Perm(len(cands)) {
+		wrds = append(wrds, cands[i])
+	}
+	sort.SliceStable(wrds, func(i, j int) bool {
+		return fp.Expsr[wrds[i].Str] < fp.Expsr[wrds[j].Str]
+	})
+	if len(wrds) > n {
+		wrds = wrds[:n]
+	}
+	return wrds
+}
+
+// Posted - add time old word was up to its exposure
+func (fp *FairPckr) Posted(wrddx int, old Wrd, from int64, to int64) {
+	if old.Str != "" && to > from {
+		fp.Expsr[old.Str] += to - from
+	}
+}
+
+// WghtPckr - cycles a random slot to a word drawn by weight
+type WghtPckr struct{}
+
+// Pairs - slots cycle one at a time
+func (WghtPckr) Pairs() bool { return false }
+
+// Slots - any slot at random
+func (WghtPckr) Slots(w Wrdr) []int {
+	return []int{w.Rnd.Intn(len(w.Wrds))}
+}
+
+// Pick - draw candidates without replacement with odds by weight, words
+// weighted 0 only when nothing else is left
+func (WghtPckr) Pick(w Wrdr, cands []Wrd, n int) []Wrd {
+	left := append([]Wrd{}, cands...)
+	wrds := []Wrd{}
+	for len(wrds) < n && len(left) > 0 {
+		ttl := 0.0
+		for _, wrd := range left {
+			ttl += wrdWght(wrd.Str)
+		}
+		i := -1
+		if ttl > 0 {
+			r := w.Rnd.Float64() * ttl
+			for j, wrd := range left {
+				if wght := wrdWght(wrd.Str); wght > 0 {
+					i = j // last weighted word if rounding runs past the end
+					if r -= wght; r < 0 {
+						break
+					}
+				}
+			}
+		} else {
+			i = w.Rnd.Intn(len(left))
+		}
+		wrds = append(wrds, left[i])
+		left = append(left[:i], left[i+1:]...)
+	}
+	return wrds
+}
+
+// Posted - weighted picks keep no history
+func (WghtPckr) Posted(wrddx int, old Wrd, from int64, to int64) {}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParseWghts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]float64
+		err  bool
+	}{
+		{"", map[string]float64{}, false},
+		{"Curious=2,Present=0.5", map[string]float64{"Curious": 2, "Present": 0.5}, false},
+		{" Curious = 2 , ,Quiet=0", map[string]float64{"Curious": 2, "Quiet": 0}, false},
+		{"a=b=3", map[string]float64{"a=b": 3}, false},
+		{"Curious", nil, true},
+		{"Curious=x", nil, true},
+		{"Curious=-1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWghts(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err %v", tt.in, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNewPckr(t *testing.T) {
+	tests := []struct {
+		md  string
+		err bool
+	}{
+		{"", false}, {PckRandom, false}, {PckFair, false}, {PckWeighted, false},
+		{PckMatchup, false}, {"loudest", true},
+	}
+	for _, tt := range tests {
+		if _, err := NewPckr(tt.md); (err != nil) != tt.err {
+			t.Errorf("%q: err %v", tt.md, err)
+		}
+	}
+}
+
+func TestWghtPckr(t *testing.T) {
+	defer func(ww map[string]float64) { WrdWghts = ww }(WrdWghts)
+	cands := []Wrd{{Str: "a"}, {Str: "b"}, {Str: "c"}}
+	w := Wrdr{Rnd: rand.New(rand.NewSource(1))}
+
+	tests := []struct {
+		name  string
+		wghts map[string]float64
+		n     int
+		first map[string]bool // words allowed as first pick
+	}{
+		{"only weighted first", map[string]float64{"a": 0, "b": 0}, 1, map[string]bool{"c": true}},
+		{"zero weights last", map[string]float64{"a": 0, "b": 0}, 3, map[string]bool{"c": true}},
+		{"all zero still picks", map[string]float64{"a": 0, "b": 0, "c": 0}, 2, map[string]bool{"a": true, "b": true, "c": true}},
+		{"more than there are", map[string]float64{}, 5, map[string]bool{"a": true, "b": true, "c": true}},
+	}
+	for _, tt := range tests {
+		WrdWghts = tt.wghts
+		for i := 0; i < 50; i++ {
+			wrds := WghtPckr{}.Pick(w, cands, tt.n)
+			want := tt.n
+			if want > len(cands) {
+				want = len(cands)
+			}
+			if len(wrds) != want {
+				t.Fatalf("%v: picked %v words, want %v", tt.name, len(wrds), want)
+			}
+			if !tt.first[wrds[0].Str] {
+				t.Fatalf("%v: picked %v first", tt.name, wrds[0].Str)
+			}
+			seen := make(map[string]bool)
+			for _, wrd := range wrds {
+				if seen[wrd.Str] {
+					t.Fatalf("%v: picked %v twice", tt.name, wrd.Str)
+				}
+				seen[wrd.Str] = true
+			}
+		}
+	}
+
+	// picks follow weights
+	WrdWghts = map[string]float64{"a": 3, "b": 1, "c": 0}
+	cnts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		cnts[WghtPckr{}.Pick(w, cands, 1)[0].Str]++
+	}
+	if cnts["c"] != 0 || cnts["a"] < 2700 || cnts["a"] > 3300 {
+		t.Errorf("3:1:0 weights picked %v", cnts)
+	}
+}
+
+func TestFairPckr(t *testing.T) {
+	fp := &FairPckr{Expsr: make(map[string]int64)}
+	fp.Posted(0, Wrd{Str: "a"}, 0, 5000)
+	fp.Posted(1, Wrd{Str: "b"}, 1000, 2000)
+	fp.Posted(2, Wrd{Str: "b"}, 3000, 3000) // never up
+	fp.Posted(3, Wrd{}, 0, 9000)            // empty slot
+	if fp.Expsr["a"] != 5000 || fp.Expsr["b"] != 1000 || len(fp.Expsr) != 2 {
+		t.Fatalf("exposure %v", fp.Expsr)
+	}
+
+	w := Wrdr{Rnd: rand.New(rand.NewSource(1)), Stmps: []int64{400, 100, 300}}
+	if s := fp.Slots(w); len(s) != 1 || s[0] != 1 {
+		t.Errorf("slots %v, want oldest slot 1", s)
+	}
+	cands := []Wrd{{Str: "a"}, {Str: "b"}, {Str: "c"}}
+	wrds := fp.Pick(w, cands, 2)
+	if len(wrds) != 2 || wrds[0].Str != "c" || wrds[1].Str != "b" {
+		t.Errorf("picked %v, want c then b", wrds)
+	}
+}
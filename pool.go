@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PoolFile - default word pool file, the built in pool is used if it is missing
+const PoolFile = "wordpool.json"
+
+// WrdCats - category per word from the pool file
+var WrdCats = map[string]string{}
+
+// PoolWrds - every entry of the pool file, incl words not in the live pool
+var PoolWrds []PoolWrd
+
+// PoolWrd - word pool file entry
+type PoolWrd struct {
+	Word     string   `json:"word"`
+	Color    RGB      `json:"color"` // 12 bit rgb array or hex string
+	Category string   `json:"category,omitempty"`
+	Weight   *float64 `json:"weight,omitempty"`  // 1 if missing
+	Enabled  *bool    `json:"enabled,omitempty"` // true if missing
+}
+
+// On - if word is enabled
+func (pw PoolWrd) On() bool {
+	return pw.Enabled == nil || *pw.Enabled
+}
+
+// CurPool - live pool as file entries
+func CurPool() []PoolWrd {
+	pws := []PoolWrd{}
+	for _, wrd := range WrdPool {
+		pw := PoolWrd{Word: wrd.Str, Color: wrd.Clr, Category: WrdCats[wrd.Str]}
+		if wght, has := WrdWghts[wrd.Str]; has {
+			pw.Weight = &wght
+		}
+		pws = append(pws, pw)
+	}
+	return pws
+}
+
+// ReadPool - read word pool from json or csv file, by extension
+func ReadPool(fn string) ([]PoolWrd, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(fn), ".csv") {
+		return ParsePoolCSV(bytes.NewReader(b))
+	}
+	return ParsePoolJSON(b)
+}
+
+// ParsePoolJSON - parse word pool from json array of entries
+func ParsePoolJSON(b []byte) ([]PoolWrd, error) {
+	var pws []PoolWrd
+	if err := json.Unmarshal(b, &pws); err != nil {
+		return nil, err
+	}
+	return pws, nil
+}
+
+// ParsePoolCSV - parse word pool from csv with a header naming its columns:
+// word & color are needed, category, weight & enabled are optional; colors
+// are 3 space separated 12 bit values like "0xc90 0x910 0xd30" or hex like
+// "#cc9922"
+func ParsePoolCSV(r io.Reader) ([]PoolWrd, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	rcs, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rcs) == 0 {
+		return nil, errors.New("csv word pool has no header")
+	}
+
+	cols := make(map[string]int)
+	for i, h := range rcs[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, h := range []string{"word", "color"} {
+		if _, has := cols[h]; !has {
+			return nil, fmt.Errorf("csv word pool has no %v column", h)
+		}
+	}
+	get := func(rc []string, h string) string {
+		if i, has := cols[h]; has && i < len(rc) {
+			return strings.TrimSpace(rc[i])
+		}
+		return ""
+	}
+
+	pws := []PoolWrd{}
+	for ln, rc := range rcs[1:] {
+		pw := PoolWrd{Word: get(rc, "word"), Category: get(rc, "category")}
+		if pw.Color, err = parseRGB(get(rc, "color")); err != nil {
+			return nil, fmt.Errorf("line %v: %v", ln+2, err)
+		}
+		if s := get(rc, "weight"); s != "" {
+			wght, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: bad weight '%v'", ln+2, s)
+			}
+			pw.Weight = &wght
+		}
+		if s := get(rc, "enabled"); s != "" {
+			on, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: bad enabled flag '%v'", ln+2, s)
+			}
+			pw.Enabled = &on
+		}
+		pws = append(pws, pw)
+	}
+	return pws, nil
+}
+
+// parse hex color or 3 space separated color values
+func parseRGB(s string) (RGB, error) {
+	var clr RGB
+	if strings.HasPrefix(s, "#") {
+		return ParseHex(s)
+	}
+	vs := strings.Fields(s)
+	if len(vs) != 3 {
+		return clr, fmt.Errorf("color '%v' is not 3 values", s)
+	}
+	for i, v := range vs {
+		c, err := strconv.ParseUint(v, 0, 16)
+		if err != nil {
+			return clr, fmt.Errorf("bad color value '%v'", v)
+		}
+		clr[i] = uint16(c)
+	}
+	return clr, nil
+}
+
+// CheckPool - make sure pool fills every slot with unique words & valid colors
+func CheckPool(pws []PoolWrd, slots int) error {
+	seen := make(map[string]bool)
+	on := 0
+	for i, pw := range pws {
+		if strings.TrimSpace(pw.Word) == "" {
+			return fmt.Errorf("entry %v has no word", i+1)
+		}
+		if seen[pw.Word] {
+			return fmt.Errorf("word '%v' is in the pool twice", pw.Word)
+		}
+		seen[pw.Word] = true
+		for _, c := range pw.Color {
+			if c > 0xfff {
+				return fmt.Errorf("color of '%v' is out of 12 bit range", pw.Word)
+			}
+		}
+		if pw.Weight != nil && *pw.Weight < 0 {
+			return fmt.Errorf("weight of '%v' is negative", pw.Word)
+		}
+		if pw.On() {
+			on++
+		}
+	}
+	if on < slots {
+		return fmt.Errorf("pool has %v enabled words but needs at least %v for every slot", on, slots)
+	}
+	return nil
+}
+
+// WghtOvrds - word weights from the command line, kept over the weights of
+// every pool swapped in
+var WghtOvrds = map[string]float64{}
+
+// copy of pool entries with command line weights over their own
+func ovrdWghts(pws []PoolWrd) []PoolWrd {
+	if len(WghtOvrds) == 0 {
+		return pws
+	}
+	ows := append([]PoolWrd{}, pws...)
+	for i := range ows {
+		if wght, has := WghtOvrds[ows[i].Word]; has {
+			ows[i].Weight = &wght
+		}
+	}
+	return ows
+}
+
+// SetPool - make enabled entries in the current round the live pool with
+// their weights & categories, or every enabled entry if too few are in the
+// round to fill all slots
+func SetPool(pws []PoolWrd, slots int) {
+	pws = ovrdWghts(pws)
+	PoolWrds = pws
+	on := 0
+	for _, pw := range pws {
+		if pw.On() && CurRnd.Has(pw) {
+			on++
+		}
+	}
+	rd := CurRnd
+	if on < slots {
+		log.Printf("ERROR: only %v pool words in round, using the whole pool", on)
+		rd = nil
+	}
+
+	pool := []Wrd{}
+	wghts := make(map[string]float64)
+	cats := make(map[string]string)
+	for _, pw := range pws {
+		if !pw.On() || !rd.Has(pw) {
+			continue
+		}
+		pool = append(pool, Wrd{pw.Word, pw.Color})
+		if pw.Weight != nil {
+			wghts[pw.Word] = *pw.Weight
+		}
+		if pw.Category != "" {
+			cats[pw.Word] = pw.Category
+		}
+	}
+	WrdPool = pool
+	WrdWghts = wghts
+	WrdCats = cats
+}
+
+// PoolHandlers - register word pool endpoints on default mux: GET /admin/pool
+// lists the live pool & POST /admin/pool swaps in a json or csv body, or
+// rereads the pool file if the body is empty; new pools go to the hub on pch
+func PoolHandlers(stts *Stts, pch chan []PoolWrd, fn string, slots int) {
+	http.HandleFunc("/admin/pool", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, stts.GetPool(), nil)
+			return
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var pws []PoolWrd
+		switch bs := bytes.TrimSpace(b); {
+		case len(bs) == 0:
+			pws, err = ReadPool(fn)
+		case bs[0] == '[':
+			pws, err = ParsePoolJSON(bs)
+		default:
+			pws, err = ParsePoolCSV(bytes.NewReader(bs))
+		}
+		if err == nil {
+			err = CheckPool(pws, slots)
+		}
+		if err != nil {
+			log.Printf("ERROR: rejected word pool from %v: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case pch <- pws:
+			log.Printf("swapping in word pool of %v entries from %v", len(pws), r.RemoteAddr)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			log.Printf("ERROR: word pool dropped, channel busy")
+			http.Error(w, "busy, try again", http.StatusServiceUnavailable)
+		}
+	})
+}
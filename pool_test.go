@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePoolJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []PoolWrd
+		err  bool
+	}{
+		{"array color", `[{"word": "Calm", "color": [0, 2048, 4095], "category": "mood"}]`,
+			[]PoolWrd{{Word: "Calm", Color: RGB{0, 2048, 4095}, Category: "mood"}}, false},
+		{"hex color", `[{"word": "Bold", "color": "#ff8000"}]`,
+			[]PoolWrd{{Word: "Bold", Color: RGB{0xfff, 0x808, 0}}}, false},
+		{"bad hex color", `[{"word": "Bold", "color": "#ff80"}]`, nil, true},
+		{"not an array", `{"word": "Bold"}`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePoolJSON([]byte(tt.in))
+		if (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Word != tt.want[i].Word || got[i].Color != tt.want[i].Color || got[i].Category != tt.want[i].Category {
+				t.Errorf("%v: got %+v, want %+v", tt.name, got[i], tt.want[i])
+			}
+		}
+	}
+
+	// weight & enabled are left unset when missing
+	pws, err := ParsePoolJSON([]byte(`[{"word": "a", "color": "#000"}, {"word": "b", "color": "#000", "weight": 2, "enabled": false}]`))
+	if err != nil || pws[0].Weight != nil || !pws[0].On() || *pws[1].Weight != 2 || pws[1].On() {
+		t.Errorf("weight & enabled: got %+v, %v", pws, err)
+	}
+}
+
+func TestParsePoolCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []PoolWrd
+		err  bool
+	}{
+		{"values", "word,color\nCalm,0xc90 0x910 0xd30\n",
+			[]PoolWrd{{Word: "Calm", Color: RGB{0xc90, 0x910, 0xd30}}}, false},
+		{"hex", "word,color\nBold,#cc9922\n",
+			[]PoolWrd{{Word: "Bold", Color: RGB{0xccc, 0x999, 0x222}}}, false},
+		{"columns in any order & case", " Category ,COLOR, Word\nmood,1 2 3,Calm\n",
+			[]PoolWrd{{Word: "Calm", Color: RGB{1, 2, 3}, Category: "mood"}}, false},
+		{"short row", "word,color,category\nCalm,1 2 3\n",
+			[]PoolWrd{{Word: "Calm", Color: RGB{1, 2, 3}}}, false},
+		{"header only", "word,color\n", []PoolWrd{}, false},
+		{"empty", "", nil, true},
+		{"no color column", "word,category\nCalm,mood\n", nil, true},
+		{"no word column", "name,color\nCalm,1 2 3\n", nil, true},
+		{"two values", "word,color\nCalm,1 2\n", nil, true},
+		{"bad value", "word,color\nCalm,1 2 x\n", nil, true},
+		{"value past 16 bits", "word,color\nCalm,1 2 70000\n", nil, true},
+		{"bad hex", "word,color\nCalm,#12\n", nil, true},
+		{"bad weight", "word,color,weight\nCalm,1 2 3,heavy\n", nil, true},
+		{"bad enabled", "word,color,enabled\nCalm,1 2 3,maybe\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePoolCSV(strings.NewReader(tt.in))
+		if (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Word != tt.want[i].Word || got[i].Color != tt.want[i].Color || got[i].Category != tt.want[i].Category {
+				t.Errorf("%v: got %+v, want %+v", tt.name, got[i], tt.want[i])
+			}
+		}
+	}
+
+	pws, err := ParsePoolCSV(strings.NewReader("word,color,weight,enabled\na,0 0 0,0.5,false\nb,0 0 0,,\n"))
+	if err != nil || *pws[0].Weight != 0.5 || pws[0].On() || pws[1].Weight != nil || !pws[1].On() {
+		t.Errorf("weight & enabled: got %+v, %v", pws, err)
+	}
+}
+
+func TestCheckPool(t *testing.T) {
+	neg, off := -1.0, false
+	ok := []PoolWrd{{Word: "a"}, {Word: "b", Color: RGB{0xfff, 0xfff, 0xfff}}, {Word: "c"}}
+	tests := []struct {
+		name  string
+		pws   []PoolWrd
+		slots int
+		err   bool
+	}{
+		{"ok", ok, 3, false},
+		{"fewer slots", ok, 2, false},
+		{"too few words", ok, 4, true},
+		{"duplicate", []PoolWrd{{Word: "a"}, {Word: "b"}, {Word: "a"}}, 2, true},
+		{"blank word", []PoolWrd{{Word: "a"}, {Word: "  "}}, 1, true},
+		{"color past 12 bits", []PoolWrd{{Word: "a", Color: RGB{0, 0x1000, 0}}}, 1, true},
+		{"negative weight", []PoolWrd{{Word: "a", Weight: &neg}}, 1, true},
+		{"disabled words dont fill slots", []PoolWrd{{Word: "a"}, {Word: "b", Enabled: &off}}, 2, true},
+		{"disabled words can be spare", []PoolWrd{{Word: "a"}, {Word: "b", Enabled: &off}}, 1, false},
+	}
+	for _, tt := range tests {
+		if err := CheckPool(tt.pws, tt.slots); (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PwrRls - fraction of the way back to full brightness a limited lamp moves
+// each frame once its draw drops, so limiting eases off instead of popping
+const PwrRls = 0.05
+
+// PwrDflt - typical led draw with no budgets
+var PwrDflt = PwrCfg{LedMA: [3]float64{20, 20, 20}}
+
+// Pwr - current draw estimate & budgets, budgets of 0 are unlimited
+var Pwr = PwrDflt
+
+// PwrCfg - mA each led channel draws at full scale & max mA per lamp & for
+// every lamp together
+type PwrCfg struct {
+	LedMA [3]float64 `json:"led_ma"`
+	LmpMA float64    `json:"lamp_ma,omitempty"`
+	TtlMA float64    `json:"total_ma,omitempty"`
+}
+
+// On - if any budget is set
+func (pc PwrCfg) On() bool {
+	return pc.LmpMA > 0 || pc.TtlMA > 0
+}
+
+// ParseLedMA - parse mA per channel at full scale, one value for all
+// channels or 3 comma separated like 20,18,22
+func ParseLedMA(s string) ([3]float64, error) {
+	var ma [3]float64
+	vs := strings.Split(s, ",")
+	if len(vs) != 1 && len(vs) != 3 {
+		return ma, fmt.Errorf("led mA '%v' is not 1 or 3 values", s)
+	}
+	for c := range ma {
+		v, err := strconv.ParseFloat(strings.TrimSpace(vs[c%len(vs)]), 64)
+		if err != nil || v < 0 {
+			return ma, fmt.Errorf("bad led mA '%v'", vs[c%len(vs)])
+		}
+		ma[c] = v
+	}
+	return ma, nil
+}
+
+// Draw - estimated mA of leds showing calibrated clrs
+func (pc PwrCfg) Draw(clrs [LampSize]FRGB) float64 {
+	ma := 0.0
+	for _, clr := range clrs {
+		for c := 0; c < 3; c++ {
+			ma += clr[c] * pc.LedMA[c]
+		}
+	}
+	return ma
+}
+
+// limit - scale calibrated lmp colors down to stay in budgets, so gamma &
+// gains are counted as sent; lamps drop to their budget at once to protect
+// the supplies & ease back up over frames
+func (blnkr *Blnkr) limit() {
+	if blnkr.Scls == nil {
+		blnkr.Scls = make(map[string]float64)
+	}
+
+	// per lamp budgets first, then share out what is left of the total
+	drws := make(map[string]float64)
+	trgts := make(map[string]float64)
+	drw, ttl := 0.0, 0.0
+	for ip, lmp := range blnkr.Lmps {
+		d := Pwr.Draw(lmp.Cal)
+		drws[ip] = d
+		drw += d
+		trgts[ip] = 1
+		if Pwr.LmpMA > 0 && d > Pwr.LmpMA {
+			trgts[ip] = Pwr.LmpMA / d
+		}
+		ttl += d * trgts[ip]
+	}
+	if Pwr.TtlMA > 0 && ttl > Pwr.TtlMA {
+		for ip := range trgts {
+			trgts[ip] *= Pwr.TtlMA / ttl
+		}
+	}
+
+	out := 0.0
+	lmtd := false
+	for ip, lmp := range blnkr.Lmps {
+		scl, has := blnkr.Scls[ip]
+		if !has || trgts[ip] < scl {
+			scl = trgts[ip]
+		} else {
+			scl += PwrRls * (trgts[ip] - scl)
+		}
+		if scl > 0.999 {
+			scl = 1
+		}
+		blnkr.Scls[ip] = scl
+
+		if scl < 1 {
+			lmtd = true
+			for i := 0; i < LampSize; i++ {
+				lmp.Cal[i] = lmp.Cal[i].Dim(scl)
+				lmp.Pnts[i].F = lmp.Pnts[i].F.Dim(scl) // dashboard & previews show it dimmed too
+			}
+		}
+		out += drws[ip] * scl
+		blnkr.Stts.LmpPwr(ip, drws[ip], scl)
+	}
+	blnkr.Stts.FrmPwr(drw, out, lmtd)
+}
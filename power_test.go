@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// blnkr with lamps whose calibrated leds are all at level, by ip
+func pwrBlnkr(lvls map[string]float64) *Blnkr {
+	blnkr := &Blnkr{Lmps: make(map[string]*Lmp)}
+	for ip, v := range lvls {
+		lmp := &Lmp{IP: ip}
+		for i := 0; i < LampSize; i++ {
+			lmp.Cal[i] = FRGB{v, v, v}
+			lmp.Pnts[i].F = FRGB{v, v, v}
+		}
+		blnkr.Lmps[ip] = lmp
+	}
+	return blnkr
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseLedMA(t *testing.T) {
+	tests := []struct {
+		in   string
+		want [3]float64
+		err  bool
+	}{
+		{"20", [3]float64{20, 20, 20}, false},
+		{"20,18.5, 22", [3]float64{20, 18.5, 22}, false},
+		{"0", [3]float64{}, false},
+		{"20,18", [3]float64{}, true},
+		{"-1", [3]float64{}, true},
+		{"x", [3]float64{}, true},
+		{"", [3]float64{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLedMA(tt.in)
+		if (err != nil) != tt.err || (!tt.err && got != tt.want) {
+			t.Errorf("%q: got %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	pc := PwrCfg{LedMA: [3]float64{20, 10, 30}}
+	tests := []struct {
+		name string
+		clr  FRGB
+		want float64
+	}{
+		{"black", FRGB{}, 0},
+		{"white", FRGB{1, 1, 1}, 60 * LampSize},
+		{"half red", FRGB{0.5, 0, 0}, 10 * LampSize},
+		{"channels weighted", FRGB{0, 1, 0.5}, 25 * LampSize},
+	}
+	for _, tt := range tests {
+		var clrs [LampSize]FRGB
+		for i := range clrs {
+			clrs[i] = tt.clr
+		}
+		if got := pc.Draw(clrs); !near(got, tt.want) {
+			t.Errorf("%v: got %v mA, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	defer func(p PwrCfg) { Pwr = p }(Pwr)
+	full := 60.0 * LampSize // mA of a lamp at full white
+
+	tests := []struct {
+		name   string
+		lmpma  float64
+		ttlma  float64
+		lvls   map[string]float64
+		want   map[string]float64 // scale per lamp
+		maxttl float64            // most mA of every lamp after limiting, 0 unchecked
+	}{
+		{"no budgets", 0, 0, map[string]float64{"a": 1, "b": 0.5}, map[string]float64{"a": 1, "b": 1}, 0},
+		{"under budgets", full, 2 * full, map[string]float64{"a": 1, "b": 0.5}, map[string]float64{"a": 1, "b": 1}, 0},
+		{"lamp budget", full / 2, 0, map[string]float64{"a": 1, "b": 0.25}, map[string]float64{"a": 0.5, "b": 1}, 0},
+		{"total budget shared evenly", 0, 0.75 * full, map[string]float64{"a": 1, "b": 0.5}, map[string]float64{"a": 0.5, "b": 0.5}, 0.75 * full},
+		{"lamp budget then total", 0.625 * full, 0.625 * full, map[string]float64{"a": 1, "b": 0.5},
+			map[string]float64{"a": 0.625 * 0.625 / 1.125, "b": 0.625 / 1.125}, 0.625 * full},
+		{"black lamp", full / 2, full / 2, map[string]float64{"a": 0}, map[string]float64{"a": 1}, 0},
+	}
+	for _, tt := range tests {
+		Pwr = PwrCfg{LedMA: [3]float64{20, 20, 20}, LmpMA: tt.lmpma, TtlMA: tt.ttlma}
+		blnkr := pwrBlnkr(tt.lvls)
+		blnkr.limit()
+		ttl := 0.0
+		for ip, lmp := range blnkr.Lmps {
+			scl := blnkr.Scls[ip]
+			if !near(scl, tt.want[ip]) {
+				t.Errorf("%v: lamp %v scale %v, want %v", tt.name, ip, scl, tt.want[ip])
+			}
+			if want := tt.lvls[ip] * tt.want[ip]; !near(lmp.Cal[0][0], want) || !near(lmp.Pnts[0].F[0], want) {
+				t.Errorf("%v: lamp %v led at %v & preview %v, want %v", tt.name, ip, lmp.Cal[0][0], lmp.Pnts[0].F[0], want)
+			}
+			ttl += Pwr.Draw(lmp.Cal)
+		}
+		if tt.maxttl > 0 && ttl > tt.maxttl+1e-6 {
+			t.Errorf("%v: draws %v mA over total budget %v", tt.name, ttl, tt.maxttl)
+		}
+	}
+}
+
+func TestLimitEase(t *testing.T) {
+	defer func(p PwrCfg) { Pwr = p }(Pwr)
+	Pwr = PwrCfg{LedMA: [3]float64{20, 20, 20}, LmpMA: 30 * LampSize}
+
+	// over budget drops at once
+	blnkr := pwrBlnkr(map[string]float64{"a": 1})
+	blnkr.Scls = map[string]float64{"a": 1}
+	blnkr.limit()
+	if !near(blnkr.Scls["a"], 0.5) {
+		t.Fatalf("over budget: scale %v, want 0.5", blnkr.Scls["a"])
+	}
+
+	// once under budget eases back by PwrRls of the way each frame
+	scl := 0.5
+	for f := 0; f < 3; f++ {
+		blnkr.Lmps["a"] = pwrBlnkr(map[string]float64{"a": 0.25}).Lmps["a"]
+		blnkr.limit()
+		scl += PwrRls * (1 - scl)
+		if !near(blnkr.Scls["a"], scl) || !near(blnkr.Lmps["a"].Cal[0][0], 0.25*scl) {
+			t.Fatalf("frame %v: scale %v, want %v", f, blnkr.Scls["a"], scl)
+		}
+	}
+
+	// & snaps to full close to it
+	blnkr.Scls["a"] = 0.9995
+	blnkr.limit()
+	if blnkr.Scls["a"] != 1 {
+		t.Errorf("near full: scale %v, want 1", blnkr.Scls["a"])
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// PreviewDelay - min delay between frames streamed to preview clients in ms
+const PreviewDelay = 100 // ~10hz
+
+// PrvwClient - holds channel to goroutine with websocket connection to preview client
+type PrvwClient struct {
+	FrmCh chan []byte
+	Dest  string // ws.Request().RemoteAddr
+}
+
+// preview frame format (binary websocket message):
+//   uint32 big endian frame number
+//   3 bytes r, g, b (8 bit) for each led in blnkr.Leds order
+
+// PreviewFrame - encode current pnt colors as compact binary preview frame
+func (blnkr *Blnkr) PreviewFrame() []byte {
+	buf := make([]byte, 4, 4+3*len(blnkr.Leds))
+	binary.BigEndian.PutUint32(buf, uint32(blnkr.Frm))
+	for _, led := range blnkr.Leds {
+		clr := blnkr.Lmps[led.IP].Pnts[led.Index].Clr
+		buf = append(buf, byte(clr[0]>>4), byte(clr[1]>>4), byte(clr[2]>>4))
+	}
+	return buf
+}
+
+// pass preview frame up frame channel if throttle delay has passed
+func (blnkr *Blnkr) preview() {
+	if blnkr.Frmch == nil || blnkr.Frm%(PreviewDelay/UpdateDelay) != 0 {
+		return
+	}
+	select {
+	case blnkr.Frmch <- blnkr.PreviewFrame():
+	default: // drop frame if nobody is keeping up
+	}
+}
+
+// PreviewHandlers - register led layout & frame websocket handlers on default mux
+// frames received on frmch are fanned out to every connected preview client
+func PreviewHandlers(leds []Led, frmch chan []byte) {
+
+	// channels to add & remove preview clients
+	pch := make(chan PrvwClient, 4)
+	gch := make(chan PrvwClient, 4)
+
+	go func() {
+		pcdx := make(map[string]PrvwClient)
+		for {
+			select {
+			case pc := <-pch:
+				log.Printf("new preview client at %v", pc.Dest)
+				pcdx[pc.Dest] = pc
+			case pc := <-gch:
+				delete(pcdx, pc.Dest)
+			case frm := <-frmch:
+				for _, pc := range pcdx {
+					select {
+					case pc.FrmCh <- frm:
+					default: // slow clients just skip frames
+					}
+				}
+			}
+		}
+	}()
+
+	// led positions in frame order
+	http.HandleFunc("/preview/layout", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(leds); err != nil {
+			log.Printf("ERROR: failed to encode led layout: %v", err)
+		}
+	})
+
+	// frames topic, binary messages only flow server -> client
+	http.Handle("/frames", websocket.Handler(func(ws *websocket.Conn) {
+		pc := PrvwClient{make(chan []byte, 4), ws.Request().RemoteAddr}
+		pch <- pc
+		defer func() { gch <- pc }()
+
+		// notice when the client goes away, we dont expect it to say anything
+		done := make(chan bool)
+		go func() {
+			var msg []byte
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+			close(done)
+		}()
+
+		for {
+			select {
+			case frm := <-pc.FrmCh:
+				if err := websocket.Message.Send(ws, frm); err != nil {
+					log.Printf("ERROR: failed to send frame to preview client %v: %v", pc.Dest, err)
+					return
+				}
+			case <-done:
+				log.Printf("preview client at %v gone", pc.Dest)
+				return
+			}
+		}
+	}))
+
+	log.Println("serving led preview at http://localhost:8888/admin/preview.html")
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScnrVt - scripted vote at time offset from start of scenario
+type ScnrVt struct {
+	T   int64 // ms from start
+	Stn int
+	Wrd Wrd
+}
+
+// Prjctn - flattens led coords to image pixels for a fixed view
+type Prjctn struct {
+	U, V int  // coord axes (0 x, 1 y, 2 z) mapped to image x & y
+	FlpV bool // flip v so that up is up
+	Mn   [3]float64
+	Scl  float64 // px per coord unit
+	Mrgn int
+	W, H int
+}
+
+// NewPrjctn - fit leds into an image of given width for view top|side|front
+func NewPrjctn(leds []Led, view string, width int) (*Prjctn, error) {
+	p := Prjctn{Mrgn: 16}
+	switch view {
+	case "top":
+		p.U, p.V = 0, 2
+	case "side":
+		p.U, p.V, p.FlpV = 0, 1, true
+	case "front":
+		p.U, p.V, p.FlpV = 2, 1, true
+	default:
+		return nil, fmt.Errorf("unknown view '%v', expected top, side or front", view)
+	}
+	if len(leds) == 0 {
+		return nil, errors.New("no leds to project")
+	}
+
+	// find bounds of led coords
+	mx := [3]float64{}
+	for i, led := range leds {
+		c := [3]float64{led.X, led.Y, led.Z}
+		for k := 0; k < 3; k++ {
+			if i == 0 || c[k] < p.Mn[k] {
+				p.Mn[k] = c[k]
+			}
+			if i == 0 || c[k] > mx[k] {
+				mx[k] = c[k]
+			}
+		}
+	}
+
+	// scale to fit width, height follows aspect of view
+	du, dv := mx[p.U]-p.Mn[p.U], mx[p.V]-p.Mn[p.V]
+	if du <= 0 {
+		du = 1
+	}
+	p.W = width
+	p.Scl = float64(width-2*p.Mrgn) / du
+	p.H = int(dv*p.Scl) + 2*p.Mrgn
+	if p.H < 4*p.Mrgn {
+		p.H = 4 * p.Mrgn
+	}
+	if p.FlpV {
+		p.Mn[p.V] = mx[p.V] // measure down from top
+	}
+	return &p, nil
+}
+
+// Px - image pixel for led coords
+func (p *Prjctn) Px(led Led) image.Point {
+	c := [3]float64{led.X, led.Y, led.Z}
+	u := (c[p.U] - p.Mn[p.U]) * p.Scl
+	v := (c[p.V] - p.Mn[p.V]) * p.Scl
+	if p.FlpV {
+		v = -v
+	}
+	return image.Pt(p.Mrgn+int(u), p.Mrgn+int(v))
+}
+
+// RingClr - outline so unlit leds still show the layout
+var RingClr = color.RGBA{0x30, 0x30, 0x30, 0xff}
+
+// Draw - draw current pnt colors of blnkr as outlined discs of radius r
+func (p *Prjctn) Draw(blnkr *Blnkr, r int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, p.W, p.H))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	for _, led := range blnkr.Leds {
+		clr := blnkr.Lmps[led.IP].Pnts[led.Index].Clr
+		c := color.RGBA{uint8(clr[0] >> 4), uint8(clr[1] >> 4), uint8(clr[2] >> 4), 0xff}
+		ctr := p.Px(led)
+		for y := -r; y <= r; y++ {
+			for x := -r; x <= r; x++ {
+				d := x*x + y*y
+				if d <= (r-1)*(r-1) {
+					img.SetRGBA(ctr.X+x, ctr.Y+y, c)
+				} else if d <= r*r {
+					img.SetRGBA(ctr.X+x, ctr.Y+y, RingClr)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// GenVts - votes cycling through stations at fixed interval with random words
+func GenVts(stns []int, every, dur int64, rnd *rand.Rand) []ScnrVt {
+	vts := []ScnrVt{}
+	if every <= 0 || len(stns) == 0 {
+		return vts
+	}
+	for i, t := 0, every; t <= dur; i, t = i+1, t+every {
+		vts = append(vts, ScnrVt{
+			T:   t,
+			Stn: stns[i%len(stns)],
+			Wrd: WrdPool[rnd.Intn(len(WrdPool))],
+		})
+	}
+	return vts
+}
+
+// ReadScnr - read scripted votes, one per line as '<offset> <station> [word]'
+// e.g. '2.5s 101 Curious'; blank lines & lines starting with # are skipped
+func ReadScnr(fn string, rnd *rand.Rand) ([]ScnrVt, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vts := []ScnrVt{}
+	sc := bufio.NewScanner(f)
+	for ln := 1; sc.Scan(); ln++ {
+		flds := strings.Fields(sc.Text())
+		if len(flds) == 0 || strings.HasPrefix(flds[0], "#") {
+			continue
+		}
+		if len(flds) < 2 {
+			return nil, fmt.Errorf("%v:%v: expected '<offset> <station> [word]'", fn, ln)
+		}
+		ofst, err := time.ParseDuration(flds[0])
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", fn, ln, err)
+		}
+		stn, err := strconv.Atoi(flds[1])
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: bad station: %v", fn, ln, err)
+		}
+		vt := ScnrVt{T: ofst.Nanoseconds() / 1000000, Stn: stn}
+		if len(flds) > 2 {
+			wrd, has := FindWrd(flds[2])
+			if !has {
+				return nil, fmt.Errorf("%v:%v: word '%v' not in pool", fn, ln, flds[2])
+			}
+			vt.Wrd = wrd
+		} else {
+			vt.Wrd = WrdPool[rnd.Intn(len(WrdPool))]
+		}
+		vts = append(vts, vt)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(vts, func(i, j int) bool { return vts[i].T < vts[j].T })
+	return vts, nil
+}
+
+// FindWrd - look up word in pool by string, ignoring case
+func FindWrd(str string) (Wrd, bool) {
+	for _, wrd := range WrdPool {
+		if strings.EqualFold(wrd.Str, str) {
+			return wrd, true
+		}
+	}
+	return Wrd{}, false
+}
+
+// RenderCmd - render scripted scenario through blnkr to png frames or a gif
+func RenderCmd(args []string) error {
+	fset := flag.NewFlagSet("render", flag.ExitOnError)
+	ledfn := fset.String("leds", "led_locations.json", "led position file")
+	stnsf := fset.String("stations", "101,102,103", "comma separated stations to vote at in turn")
+	every := fset.Duration("every", 2*time.Second, "interval between generated votes")
+	dur := fset.Duration("for", 60*time.Second, "length of scenario")
+	scnrfn := fset.String("script", "", "file of scripted votes '<offset> <station> [word]', replaces generated votes")
+	view := fset.String("view", "top", "projection: top, side or front")
+	fps := fset.Int("fps", 10, "output frames per second")
+	width := fset.Int("width", 640, "image width in px")
+	dot := fset.Int("dot", 4, "led radius in px")
+	seed := fset.Int64("seed", 1, "random seed for word choice")
+	lyrfn := fset.String("layers", "", "json file of wave layers, empty for defaults")
+	prtfn := fset.String("particles", "", "json file of particle settings")
+	out := fset.String("out", "render.gif", "output .gif file or directory for png frames")
+	fset.Parse(args)
+
+	leddata, err := ioutil.ReadFile(*ledfn)
+	if err != nil {
+		return err
+	}
+	blnkr, err := NewBlnkr(leddata)
+	if err != nil {
+		return err
+	}
+	if err := blnkr.SetLyrs(*lyrfn); err != nil {
+		return err
+	}
+	if err := blnkr.LoadPrtcls(*prtfn); err != nil {
+		return err
+	}
+	prj, err := NewPrjctn(blnkr.Leds, *view, *width)
+	if err != nil {
+		return err
+	}
+
+	// build vote list from script or generator
+	rnd := rand.New(rand.NewSource(*seed))
+	var vts []ScnrVt
+	if *scnrfn != "" {
+		vts, err = ReadScnr(*scnrfn, rnd)
+		if err != nil {
+			return err
+		}
+	} else {
+		stns := []int{}
+		for _, s := range strings.Split(*stnsf, ",") {
+			stn, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("bad station '%v': %v", s, err)
+			}
+			stns = append(stns, stn)
+		}
+		vts = GenVts(stns, every.Nanoseconds()/1000000, dur.Nanoseconds()/1000000, rnd)
+	}
+
+	// only keep every nth frame to match output rate
+	nth := 1
+	if *fps > 0 {
+		nth = int(1000 / int64(*fps) / UpdateDelay)
+	}
+	if nth < 1 {
+		nth = 1
+	}
+
+	isgif := strings.EqualFold(filepath.Ext(*out), ".gif")
+	if !isgif {
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			return err
+		}
+	}
+	anim := gif.GIF{}
+
+	// step through scenario in virtual time one frame at a time, the last
+	// frame reaching the end so votes right at it are cast; everything timed
+	// off the clock, like noise, particles & transitions, runs on it too
+	durms := dur.Nanoseconds() / 1000000
+	vdx, n := 0, 0
+	vt := int64(0)
+	clck = func() int64 { return vt }
+	for t := int64(UpdateDelay); t-UpdateDelay < durms; t += UpdateDelay {
+		vt = t
+		for vdx < len(vts) && vts[vdx].T <= t {
+			blnkr.Vote(VtClr{vts[vdx].Stn, vts[vdx].Wrd.Clr})
+			vdx++
+		}
+		blnkr.Render()
+
+		if blnkr.Frm%int64(nth) != 0 {
+			continue
+		}
+		img := prj.Draw(blnkr, *dot)
+		if isgif {
+			pimg := image.NewPaletted(img.Bounds(), palette.Plan9)
+			draw.FloydSteinberg.Draw(pimg, img.Bounds(), img, image.Point{})
+			anim.Image = append(anim.Image, pimg)
+			anim.Delay = append(anim.Delay, nth*UpdateDelay/10)
+		} else {
+			fn := filepath.Join(*out, fmt.Sprintf("frame_%05d.png", n))
+			if err := writePNG(fn, img); err != nil {
+				return err
+			}
+		}
+		n++
+	}
+
+	if isgif {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := gif.EncodeAll(f, &anim); err != nil {
+			return err
+		}
+	}
+	if drp := len(vts) - vdx; drp > 0 {
+		log.Printf("dropped %v scripted votes after the %v scenario, first at %vms", drp, *dur, vts[vdx].T)
+	}
+	log.Printf("rendered %v frames with %v votes to %v", n, vdx, *out)
+	return nil
+}
+
+// encode image as png file
+func writePNG(fn string, img image.Image) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
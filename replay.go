@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// ReplayCmd - feed journal back through hub & blnkr on a virtual clock
+func ReplayCmd(args []string) error {
+	fset := flag.NewFlagSet("replay", flag.ExitOnError)
+	in := fset.String("in", "", "event journal to replay")
+	speed := fset.Float64("speed", 1.0, "replay speed multiplier, 0 runs as fast as possible")
+	ledfn := fset.String("leds", "led_locations.json", "led position file")
+	wlfn := fset.String("wordlog", "replay_wordlog.json", "file to write reproduced word log to, empty to discard")
+	send := fset.Bool("send", false, "udpcast reproduced frames to the lamps")
+	recfn := fset.String("rec", "", "file to record reproduced frames to")
+	lyrfn := fset.String("layers", "", "json file of wave layers, empty for defaults, as journaled unless given")
+	outbits := fset.Int("out-bits", DfltBits, "bit depth of colors sent & recorded, as journaled unless given")
+	dthr := fset.Bool("dither", false, "temporally dither colors sent & recorded, as journaled unless given")
+	scnfn := fset.String("scenes", "", "json file of scenes, empty for the default scene only, as journaled unless given")
+	prtfn := fset.String("particles", "", "json file of particle settings, as journaled unless given")
+	shwfn := fset.String("schedule", "", "json file of show programs by time of day, as journaled unless given")
+	tail := fset.Duration("tail", 10*time.Second, "keep rendering this long after the last event")
+	fset.Parse(args)
+
+	if *in == "" {
+		return errors.New("replay needs -in <journal>")
+	}
+	if *speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+	if *outbits < 1 || *outbits > 16 {
+		return errors.New("out-bits must be between 1 & 16")
+	}
+	given := make(map[string]bool) // flags that override the journal
+	fset.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	evs, err := ReadJrnl(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// run every part of the server off the virtual clock
+	strt := evs[0]
+	vt := strt.Time
+	clck = func() int64 { return vt }
+	strt.Cfg.Apply()
+	if given["out-bits"] {
+		OutBits = *outbits
+	}
+	if given["dither"] {
+		Dither = *dthr
+	}
+
+	var wlf io.Writer = ioutil.Discard
+	if *wlfn != "" {
+		wf, err := os.Create(*wlfn)
+		if err != nil {
+			return err
+		}
+		defer wf.Close()
+		wlf = wf
+	}
+	wrdr := NewWrdr(strt.Cfg.Stations, NewJSONStr(wlf), rand.New(rand.NewSource(strt.Seed)))
+
+	leddata, err := ioutil.ReadFile(*ledfn)
+	if err != nil {
+		return err
+	}
+	blnkr, err := NewBlnkr(leddata)
+	if err != nil {
+		return err
+	}
+	blnkr.Strt = strt.Time
+	blnkr.ClbrFn = ClbrPath(*ledfn)
+	blnkr.LoadClbr()
+
+	// lighting as journaled, files given replace it
+	blnkr.Lyrs = DfltLyrs
+	if CurLyrs != nil {
+		blnkr.Lyrs = CurLyrs
+	}
+	if given["layers"] {
+		if err := blnkr.SetLyrs(*lyrfn); err != nil {
+			return err
+		}
+	}
+	blnkr.SetScns(CurScns)
+	if given["scenes"] {
+		if err := blnkr.LoadScns(*scnfn); err != nil {
+			return err
+		}
+	}
+	blnkr.SetPrtcls(CurPCfg)
+	if given["particles"] {
+		if err := blnkr.LoadPrtcls(*prtfn); err != nil {
+			return err
+		}
+	}
+	shw := &ShwSchd{}
+	if CurShw != nil {
+		if shw, err = CurShw.Schd(); err != nil {
+			return err
+		}
+	}
+	if err := blnkr.SetShw(shw); err != nil {
+		return err
+	}
+	if given["schedule"] {
+		if err := blnkr.LoadShw(*shwfn); err != nil {
+			return err
+		}
+		blnkr.Shw.Loc = shw.Loc // still in the zone the journal ran in
+	}
+	if *recfn != "" {
+		blnkr.Rcrdr, err = NewFrmWrtr(*recfn, blnkr.IPs, vt, OutBits)
+		if err != nil {
+			return err
+		}
+		defer blnkr.StopRec()
+	}
+
+	rgbch := make(chan VtClr, 64)
+	cmdch := make(chan string, 4)
+	thmch := make(chan *Theme, 4)
+	tchch := make(chan int, 64)
+	wrdch := make(chan []Wrd, 4)
+	hub := NewHub(strt.Cfg.Stations, wrdr, rgbch, cmdch)
+	hub.Thmch = thmch
+	hub.Tchch = tchch
+	hub.Wrdch = wrdch
+	blnkr.Thm = CurThm
+	blnkr.Wrds = append([]Wrd{}, wrdr.Wrds...)
+
+	// step frame by frame, applying events that fall before each frame
+	end := evs[len(evs)-1].Time + tail.Nanoseconds()/1000000
+	wall := time.Now()
+	edx := 1
+	for ft := strt.Time + UpdateDelay; ft <= end; ft += UpdateDelay {
+		for edx < len(evs) && evs[edx].Time <= ft {
+			vt = evs[edx].Time
+			replayEvt(hub, blnkr.Shw, evs[edx])
+			edx++
+		}
+		vt = ft
+
+		// hand blnkr what the hub sent it, in order
+		for drained := false; !drained; {
+			select {
+			case vc := <-rgbch:
+				blnkr.Vote(vc)
+			case cmd := <-cmdch:
+				if cmd != BlnkRecord && cmd != BlnkStopRecord { // dont record a replay twice
+					blnkr.Cmd(cmd)
+				}
+			case thm := <-thmch:
+				blnkr.Thm = thm
+			case _ = <-tchch:
+				blnkr.Touch()
+			case ws := <-wrdch:
+				blnkr.Wrds = ws
+			default:
+				drained = true
+			}
+		}
+
+		blnkr.Render()
+		if *send {
+			blnkr.UDPCast()
+		} else {
+			blnkr.record()
+		}
+
+		// pace against wall clock
+		if *speed > 0 {
+			due := time.Duration(float64(ft-strt.Time)/(*speed)) * time.Millisecond
+			if wait := due - time.Since(wall); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+
+	log.Printf("replayed %v events over %v frames", edx, blnkr.Frm)
+	return nil
+}
+
+// apply a single journaled event to hub or schedule
+func replayEvt(hub *Hub, shw *ShwSchd, ev JrnlEvt) {
+	switch ev.Kind {
+	case JrnlTeensy, JrnlWS:
+		if ev.Msg != nil {
+			tm := *ev.Msg
+			tm.Via = ViaUDP
+			if ev.Kind == JrnlWS {
+				tm.Via = ViaWS
+			}
+			hub.Teensy(tm)
+		}
+	case JrnlCycle:
+		hub.Cycle()
+	case JrnlCmd:
+		hub.Admin(ev.Cmd)
+	case JrnlConfig:
+		if ev.Cfg != nil {
+			ev.Cfg.Apply()
+			hub.Repool()
+			hub.Qstn()
+		}
+	case JrnlOvrd:
+		shw.SetOvrd(ev.Ovrd)
+	case JrnlStart:
+		log.Printf("WARNING: ignoring extra start event at %v", ev.Time)
+	}
+}
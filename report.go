@@ -0,0 +1,360 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WrdRprt - engagement numbers for one word
+type WrdRprt struct {
+	Word    string
+	Clr     string // css color
+	Expsr   int64  // ms on screen on any slot
+	ExpsrL  int64  // ms on screen on left slots
+	ExpsrR  int64  // ms on screen on right slots
+	Votes   int    // paired touches
+	VotesL  int
+	VotesR  int
+	PerMin  float64 // votes per minute of exposure
+	PerMinL float64
+	PerMinR float64
+	Median  int64   // median touch duration ms
+	Bias    float64 // -1 all left .. 1 all right, from per minute rates
+}
+
+// Rprt - everything shown in the html report
+type Rprt struct {
+	Title    string
+	From, To time.Time
+	Tchs     int
+	Cnts     TchCnts
+	Wrds     []WrdRprt
+	LeftVts  int
+	RightVts int
+	StnChrt  template.HTML
+	HourChrt template.HTML
+	RateChrt template.HTML
+	BiasChrt template.HTML
+}
+
+// Exposure - ms each word spent on screen per side, from post stamps
+// a post holds its slot until the next post on that slot, the next session
+// start or the end of the log
+func Exposure(lgs []WrdLg) map[string][2]int64 {
+	end := int64(0)
+	posts := make(map[tchKey][]WrdLg)
+	sssns := []int64{}
+	for _, lg := range lgs {
+		if lg.Time > end {
+			end = lg.Time
+		}
+		switch lg.Flavor {
+		case "post":
+			k := tchKey{lg.Source, lg.Choice}
+			posts[k] = append(posts[k], lg)
+		case "session_start":
+			sssns = append(sssns, lg.Time)
+		}
+	}
+	sort.Slice(sssns, func(i, j int) bool { return sssns[i] < sssns[j] })
+
+	expsr := make(map[string][2]int64)
+	for k, ps := range posts {
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].Time < ps[j].Time })
+		side := 0
+		if k.chc == "right" {
+			side = 1
+		}
+		for i, p := range ps {
+			nxt := end
+			if i+1 < len(ps) {
+				nxt = ps[i+1].Time
+			}
+			for _, ss := range sssns { // server restarted while word was up
+				if ss > p.Time && ss < nxt {
+					nxt = ss
+					break
+				}
+			}
+			if nxt > p.Time {
+				e := expsr[p.Word]
+				e[side] += nxt - p.Time
+				expsr[p.Word] = e
+			}
+		}
+	}
+	return expsr
+}
+
+// median of touch durations
+func medianDur(durs []int64) int64 {
+	if len(durs) == 0 {
+		return 0
+	}
+	sort.Slice(durs, func(i, j int) bool { return durs[i] < durs[j] })
+	m := len(durs) / 2
+	if len(durs)%2 == 0 {
+		return (durs[m-1] + durs[m]) / 2
+	}
+	return durs[m]
+}
+
+// votes per minute of exposure
+func perMin(n int, ms int64) float64 {
+	if ms <= 0 {
+		return 0
+	}
+	return float64(n) / (float64(ms) / 60000)
+}
+
+// CSSClr - css hex color for 12 bit rgb
+func CSSClr(c RGB) string {
+	return fmt.Sprintf("#%02x%02x%02x", c[0]>>4, c[1]>>4, c[2]>>4)
+}
+
+// NewRprt - compute report from word log records & paired touches
+func NewRprt(title string, lgs []WrdLg, tchs []Tch, cnts TchCnts) Rprt {
+	r := Rprt{Title: title, Tchs: len(tchs), Cnts: cnts}
+	if len(lgs) > 0 {
+		mn, mx := lgs[0].Time, lgs[0].Time
+		for _, lg := range lgs {
+			if lg.Time < mn {
+				mn = lg.Time
+			}
+			if lg.Time > mx {
+				mx = lg.Time
+			}
+		}
+		r.From, r.To = time.Unix(0, mn*1000000), time.Unix(0, mx*1000000)
+	}
+
+	// gather votes & durations per word
+	expsr := Exposure(lgs)
+	idx := make(map[string]*WrdRprt)
+	durs := make(map[string][]int64)
+	get := func(w string) *WrdRprt {
+		wr, has := idx[w]
+		if !has {
+			wr = &WrdRprt{Word: w, Clr: "#888888"}
+			if wrd, has := FindWrd(w); has {
+				wr.Clr = CSSClr(wrd.Clr)
+			}
+			idx[w] = wr
+		}
+		return wr
+	}
+	for w := range expsr {
+		get(w)
+	}
+	for _, t := range tchs {
+		wr := get(t.Word)
+		wr.Votes++
+		if t.Choice == "right" {
+			wr.VotesR++
+			r.RightVts++
+		} else {
+			wr.VotesL++
+			r.LeftVts++
+		}
+		durs[t.Word] = append(durs[t.Word], t.Dur)
+	}
+
+	for w, wr := range idx {
+		e := expsr[w]
+		wr.ExpsrL, wr.ExpsrR, wr.Expsr = e[0], e[1], e[0]+e[1]
+		wr.PerMin = perMin(wr.Votes, wr.Expsr)
+		wr.PerMinL = perMin(wr.VotesL, wr.ExpsrL)
+		wr.PerMinR = perMin(wr.VotesR, wr.ExpsrR)
+		if wr.PerMinL+wr.PerMinR > 0 {
+			wr.Bias = (wr.PerMinR - wr.PerMinL) / (wr.PerMinR + wr.PerMinL)
+		}
+		wr.Median = medianDur(durs[w])
+		r.Wrds = append(r.Wrds, *wr)
+	}
+	sort.Slice(r.Wrds, func(i, j int) bool {
+		if r.Wrds[i].PerMin != r.Wrds[j].PerMin {
+			return r.Wrds[i].PerMin > r.Wrds[j].PerMin
+		}
+		return r.Wrds[i].Word < r.Wrds[j].Word
+	})
+
+	// charts
+	lbls, vals, clrs := []string{}, []float64{}, []string{}
+	for _, wr := range r.Wrds {
+		lbls = append(lbls, wr.Word)
+		vals = append(vals, wr.PerMin)
+		clrs = append(clrs, wr.Clr)
+	}
+	r.RateChrt = SVGBars(lbls, vals, clrs, "%.2f")
+
+	vals = vals[:0]
+	for _, wr := range r.Wrds {
+		vals = append(vals, wr.Bias)
+	}
+	r.BiasChrt = SVGBias(lbls, vals, clrs)
+
+	lbls, vals, clrs = []string{}, []float64{}, []string{}
+	for _, s := range SmryBy(tchs, func(t Tch) string { return fmt.Sprintf("%v %v", t.Source, t.Choice) }) {
+		lbls = append(lbls, s.Key)
+		vals = append(vals, float64(s.Count))
+		clrs = append(clrs, "#6a9fd8")
+	}
+	r.StnChrt = SVGBars(lbls, vals, clrs, "%.0f")
+
+	hrs := [24]int{}
+	for _, t := range tchs {
+		hrs[time.Unix(0, t.Start*1000000).Hour()]++
+	}
+	lbls, vals, clrs = []string{}, []float64{}, []string{}
+	for h, n := range hrs {
+		lbls = append(lbls, fmt.Sprintf("%02d:00", h))
+		vals = append(vals, float64(n))
+		clrs = append(clrs, "#d8a96a")
+	}
+	r.HourChrt = SVGBars(lbls, vals, clrs, "%.0f")
+
+	return r
+}
+
+// chart layout in px
+const (
+	chrtRow  = 18  // height of each bar row
+	chrtLbl  = 110 // width of label column
+	chrtBar  = 360 // width of bar area
+	chrtVal  = 60  // width of value column
+	chrtWdth = chrtLbl + chrtBar + chrtVal
+)
+
+// SVGBars - horizontal bar chart with one labelled row per value
+func SVGBars(lbls []string, vals []float64, clrs []string, vfmt string) template.HTML {
+	mx := 0.0
+	for _, v := range vals {
+		if v > mx {
+			mx = v
+		}
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg width="%d" height="%d">`, chrtWdth, chrtRow*len(vals)+4)
+	for i, v := range vals {
+		y := i * chrtRow
+		w := 0.0
+		if mx > 0 {
+			w = v / mx * chrtBar
+		}
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chrtLbl-6, y+13, template.HTMLEscapeString(lbls[i]))
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, chrtLbl, y+2, w, chrtRow-4, clrs[i])
+		fmt.Fprintf(b, `<text x="%.1f" y="%d">`+vfmt+`</text>`, float64(chrtLbl)+w+4, y+13, v)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// SVGBias - diverging bar chart of -1 (left) .. 1 (right) values around a center line
+func SVGBias(lbls []string, vals []float64, clrs []string) template.HTML {
+	mid := float64(chrtLbl) + chrtBar/2
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg width="%d" height="%d">`, chrtWdth, chrtRow*(len(vals)+1)+4)
+	fmt.Fprintf(b, `<text x="%d" y="13">left</text><text x="%d" y="13" text-anchor="end">right</text>`, chrtLbl, chrtLbl+chrtBar)
+	for i, v := range vals {
+		y := (i + 1) * chrtRow
+		w := v * chrtBar / 2
+		x := mid
+		if w < 0 {
+			x, w = mid+w, -w
+		}
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chrtLbl-6, y+13, template.HTMLEscapeString(lbls[i]))
+		fmt.Fprintf(b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`, x, y+2, w, chrtRow-4, clrs[i])
+		fmt.Fprintf(b, `<text x="%d" y="%d">%+.2f</text>`, chrtLbl+chrtBar+4, y+13, v)
+	}
+	fmt.Fprintf(b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#888"/>`, mid, chrtRow, mid, chrtRow*(len(vals)+1))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var rprtTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"mins": func(ms int64) string { return fmt.Sprintf("%.1f", float64(ms)/60000) },
+	"secs": func(ms int64) string { return fmt.Sprintf("%.2f", float64(ms)/1000) },
+	"f2":   func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"when": func(t time.Time) string { return t.Format("Mon Jan 2 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.2em 0.8em; text-align: right; border-bottom: 1px solid #ddd; }
+td:first-child, th:first-child { text-align: left; }
+.swatch { display: inline-block; width: 0.9em; height: 0.9em; margin-right: 0.4em; vertical-align: middle; }
+svg text { font-size: 12px; }
+.small { color: #777; font-size: 90%; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{when .From}} &ndash; {{when .To}} &middot; {{.Tchs}} votes &middot; {{.LeftVts}} left / {{.RightVts}} right</p>
+<p class="small">skipped {{.Cnts.Stutters}} stutter ends, {{.Cnts.TooLong}} overlong touches, {{.Cnts.Orphans}} orphan ends, {{.Cnts.Open}} unfinished touches, {{.Cnts.BadLines}} bad lines</p>
+
+<h2>Which word won?</h2>
+<p class="small">votes per minute the word was on screen, so words shown longer dont win by default</p>
+{{.RateChrt}}
+
+<table>
+<tr><th>word</th><th>votes</th><th>on screen min</th><th>votes / min</th><th>median touch s</th><th>left votes / min</th><th>right votes / min</th></tr>
+{{range .Wrds}}<tr><td><span class="swatch" style="background:{{.Clr}}"></span>{{.Word}}</td><td>{{.Votes}}</td><td>{{mins .Expsr}}</td><td>{{f2 .PerMin}}</td><td>{{secs .Median}}</td><td>{{f2 .PerMinL}}</td><td>{{f2 .PerMinR}}</td></tr>
+{{end}}</table>
+
+<h2>Left / right bias</h2>
+<p class="small">per word, difference of right & left vote rates over their sum</p>
+{{.BiasChrt}}
+
+<h2>Votes per station</h2>
+{{.StnChrt}}
+
+<h2>Votes by hour</h2>
+{{.HourChrt}}
+</body>
+</html>
+`))
+
+// ReportCmd - write self contained html report of word engagement from word log
+func ReportCmd(args []string) error {
+	fset := flag.NewFlagSet("report", flag.ExitOnError)
+	in := fset.String("in", "wordlog.json", "word logs to report on, comma separated files or globs like 'wordlog*.json'")
+	out := fset.String("out", "report.html", "html file to write")
+	title := fset.String("title", "Word report", "report title")
+	mindur := fset.Duration("min", time.Duration(StttrMs)*time.Millisecond, "ignore end touches sooner than this as stutter")
+	maxdur := fset.Duration("max", 20*time.Second, "drop touches this long or longer, 0 keeps all")
+	fset.Parse(args)
+
+	lgs, bad, _, err := ReadWrdLgFiles(*in)
+	if err != nil {
+		return err
+	}
+	tchs, cnts := PairTchs(lgs, TchCfg{
+		MinDur: mindur.Nanoseconds() / 1000000,
+		MaxDur: maxdur.Nanoseconds() / 1000000,
+	})
+	cnts.BadLines = bad
+
+	of, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := rprtTmpl.Execute(of, NewRprt(*title, lgs, tchs, cnts)); err != nil {
+		of.Close()
+		return err
+	}
+	if err := of.Close(); err != nil {
+		return err
+	}
+	log.Printf("wrote report on %v votes to %v", len(tchs), *out)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+// Qstn - question of the current round, stamped on every word log record
+var Qstn = ""
+
+// CurRnd - current round, nil if the show has no rounds
+var CurRnd *Round
+
+// CurThm - lighting theme of the current round, nil for defaults
+var CurThm *Theme
+
+// Theme - lighting for a round, zero values keep the defaults
+type Theme struct {
+	WvClr     *RGB `json:"wave_color,omitempty"`
+	StrkThrsh int  `json:"streak_threshold,omitempty"`
+}
+
+// Round - a period of the show framed by a question & a word set; the set
+// is the pool words listed or in the categories listed, all if neither is
+//
+//	{
+//		"question": "How do you approach a new problem?",
+//		"words": ["Analytical", "Curious"],
+//		"categories": ["mindset"],
+//		"duration": 900000,
+//		"theme": {"wave_color": [1911, 1911, 3840], "streak_threshold": 5}
+//	}
+type Round struct {
+	Question string   `json:"question"`
+	Words    []string `json:"words,omitempty"`
+	Cats     []string `json:"categories,omitempty"`
+	Duration int64    `json:"duration"` // ms, 0 runs until advanced from admin
+	Theme    *Theme   `json:"theme,omitempty"`
+}
+
+// Has - if pool entry is in round's word set
+func (rd *Round) Has(pw PoolWrd) bool {
+	if rd == nil || (len(rd.Words) == 0 && len(rd.Cats) == 0) {
+		return true
+	}
+	for _, wrd := range rd.Words {
+		if wrd == pw.Word {
+			return true
+		}
+	}
+	for _, cat := range rd.Cats {
+		if cat == pw.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// RndSchd - rounds of the show in order & which one is on
+type RndSchd struct {
+	Rnds  []Round
+	Cur   int
+	End   int64 // when current round ends, 0 if it waits for admin
+	Slots int   // # of station slots to fill from round word sets
+}
+
+// RndStts - current round for the admin dashboard
+type RndStts struct {
+	Idx      int    `json:"index"`
+	Cnt      int    `json:"count"`
+	Question string `json:"question"`
+	End      int64  `json:"end"`
+}
+
+// ReadRounds - read json array of rounds from file
+func ReadRounds(fn string) ([]Round, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var rds []Round
+	if err := json.Unmarshal(b, &rds); err != nil {
+		return nil, err
+	}
+	return rds, nil
+}
+
+// CheckRounds - make sure every round has a question & enough words from
+// pool to fill every slot
+func CheckRounds(rds []Round, pws []PoolWrd, slots int) error {
+	if len(rds) == 0 {
+		return errors.New("no rounds")
+	}
+	inpl := make(map[string]bool)
+	for _, pw := range pws {
+		inpl[pw.Word] = true
+	}
+	for i, rd := range rds {
+		if rd.Question == "" {
+			return fmt.Errorf("round %v has no question", i+1)
+		}
+		if rd.Duration < 0 {
+			return fmt.Errorf("round %v has a negative duration", i+1)
+		}
+		for _, wrd := range rd.Words {
+			if !inpl[wrd] {
+				return fmt.Errorf("round %v word '%v' is not in the pool", i+1, wrd)
+			}
+		}
+		on := 0
+		for _, pw := range pws {
+			if pw.On() && rd.Has(pw) {
+				on++
+			}
+		}
+		if on < slots {
+			return fmt.Errorf("round %v has %v enabled words but needs at least %v for every slot", i+1, on, slots)
+		}
+	}
+	return nil
+}
+
+// Start - make round i current: set its question, theme & word set live
+func (rs *RndSchd) Start(i int) {
+	rs.Cur = i
+	rd := &rs.Rnds[i]
+	rs.End = 0
+	if rd.Duration > 0 {
+		rs.End = NowMs() + rd.Duration
+	}
+	CurRnd = rd
+	Qstn = rd.Question
+	CurThm = rd.Theme
+	SetPool(PoolWrds, rs.Slots)
+	log.Printf("round %v of %v: %v", i+1, len(rs.Rnds), Qstn)
+}
+
+// Due - if current round has run its time
+func (rs *RndSchd) Due(now int64) bool {
+	return rs != nil && rs.End > 0 && now >= rs.End
+}
+
+// Stts - current round for the admin dashboard
+func (rs *RndSchd) Stts() *RndStts {
+	return &RndStts{rs.Cur, len(rs.Rnds), rs.Rnds[rs.Cur].Question, rs.End}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+)
+
+var rndPool = []PoolWrd{
+	{Word: "Curious", Category: "mindset"},
+	{Word: "Analytical", Category: "mindset"},
+	{Word: "Calm", Category: "mood"},
+	{Word: "Bold", Category: "mood", Enabled: new(bool)},
+	{Word: "Present"},
+}
+
+func TestRoundHas(t *testing.T) {
+	tests := []struct {
+		name string
+		rd   *Round
+		want []string // words of rndPool in the set
+	}{
+		{"no round", nil, []string{"Curious", "Analytical", "Calm", "Bold", "Present"}},
+		{"no set", &Round{}, []string{"Curious", "Analytical", "Calm", "Bold", "Present"}},
+		{"words", &Round{Words: []string{"Calm", "Present"}}, []string{"Calm", "Present"}},
+		{"categories", &Round{Cats: []string{"mood"}}, []string{"Calm", "Bold"}},
+		{"both", &Round{Words: []string{"Present"}, Cats: []string{"mindset"}}, []string{"Curious", "Analytical", "Present"}},
+		{"unknown category", &Round{Cats: []string{"none"}}, nil},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, pw := range rndPool {
+			if tt.rd.Has(pw) {
+				got = append(got, pw.Word)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckRounds(t *testing.T) {
+	tests := []struct {
+		name  string
+		rds   []Round
+		slots int
+		err   bool
+	}{
+		{"ok", []Round{{Question: "q", Cats: []string{"mindset"}}, {Question: "r"}}, 2, false},
+		{"none", nil, 2, true},
+		{"no question", []Round{{Words: []string{"Calm", "Present"}}}, 2, true},
+		{"negative duration", []Round{{Question: "q", Duration: -1}}, 2, true},
+		{"word not in pool", []Round{{Question: "q", Words: []string{"Calm", "Loud"}}}, 1, true},
+		{"disabled word doesnt count", []Round{{Question: "q", Cats: []string{"mood"}}}, 2, true},
+		{"enough enabled", []Round{{Question: "q", Cats: []string{"mood"}}}, 1, false},
+		{"all slots", []Round{{Question: "q"}}, 5, true},
+	}
+	for _, tt := range tests {
+		if err := CheckRounds(tt.rds, rndPool, tt.slots); (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+		}
+	}
+}
+
+func TestRndSchd(t *testing.T) {
+	defer func(pw []PoolWrd, p []Wrd, ww map[string]float64, wc map[string]string, rd *Round, q string, thm *Theme) {
+		PoolWrds, WrdPool, WrdWghts, WrdCats, CurRnd, Qstn, CurThm = pw, p, ww, wc, rd, q, thm
+	}(PoolWrds, WrdPool, WrdWghts, WrdCats, CurRnd, Qstn, CurThm)
+	defer func(c func() int64) { clck = c }(clck)
+	now := int64(1000)
+	clck = func() int64 { return now }
+
+	thm := &Theme{StrkThrsh: 5}
+	rs := &RndSchd{Slots: 2, Rnds: []Round{
+		{Question: "first", Cats: []string{"mindset"}, Duration: 500, Theme: thm},
+		{Question: "second", Words: []string{"Calm"}},
+	}}
+	PoolWrds = rndPool
+
+	rs.Start(0)
+	if Qstn != "first" || CurThm != thm || rs.End != 1500 || len(WrdPool) != 2 {
+		t.Fatalf("first round: %q, theme %v, end %v, pool %v", Qstn, CurThm, rs.End, WrdPool)
+	}
+	for _, tt := range []struct {
+		now int64
+		due bool
+	}{{1499, false}, {1500, true}, {9000, true}} {
+		if rs.Due(tt.now) != tt.due {
+			t.Errorf("due at %v is %v", tt.now, !tt.due)
+		}
+	}
+
+	// too few words in round for every slot falls back to the whole pool
+	rs.Start(1)
+	if Qstn != "second" || CurThm != nil || rs.End != 0 || rs.Due(now+1e9) || len(WrdPool) != 4 {
+		t.Errorf("second round: %q, theme %v, end %v, pool %v", Qstn, CurThm, rs.End, WrdPool)
+	}
+	var nors *RndSchd
+	if nors.Due(now) {
+		t.Errorf("no rounds is due")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ScnCmd - prefix of blnkr commands switching scene, like "scene:calm"
+const ScnCmd = "scene:"
+
+// transition kinds
+const (
+	TrnLinear = "linear" // even crossfade
+	TrnEased  = "eased"  // crossfade starting & ending gently
+	TrnWipe   = "wipe"   // new scene sweeps across the leds along an axis
+)
+
+// WipeFthr - width of the soft edge of a wipe, as fraction of the layout
+const WipeFthr = 0.15
+
+// DfltTrns - transition between modes & show programs
+var DfltTrns = Trns{Kind: TrnEased, Dur: 1000}
+
+// Trns - how to move into a scene
+type Trns struct {
+	Kind string `json:"kind"`
+	Dur  int64  `json:"duration"`          // ms
+	Axis string `json:"axis,omitempty"`    // x, y or z for wipes
+	Rev  bool   `json:"reverse,omitempty"` // wipe from high to low coordinates
+}
+
+// Scene - a look of the wall: wave layers over a background color; scenes
+// without layers use the blnkr layers
+//
+//	{"name": "calm", "background": "#101830",
+//	 "layers": [{"name": "out", "epicenters": [1, 2, 3], "mode": "screen", "opacity": 0.5}],
+//	 "transition": {"kind": "wipe", "axis": "x", "duration": 4000}}
+type Scene struct {
+	Name string `json:"name"`
+	Lyrs []Lyr  `json:"layers,omitempty"`
+	Bg   *RGB   `json:"background,omitempty"`
+	Trns *Trns  `json:"transition,omitempty"` // into this scene, default crossfade if missing
+}
+
+// Trnstn - running transition from a scene, or a snapshot of the leds when
+// from is nil, to what blnkr renders now
+type Trnstn struct {
+	Trns
+	Strt   int64
+	From   *Scene
+	snp    map[string]*[LampSize]FRGB
+	ax     int     // wipe axis
+	mn, mx float64 // range of led coordinates along wipe axis
+}
+
+// ReadScns - read & check json array of scenes
+func ReadScns(fn string, epcntrs int) (map[string]*Scene, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var scs []Scene
+	if err := json.Unmarshal(b, &scs); err != nil {
+		return nil, err
+	}
+	scns := make(map[string]*Scene)
+	for i := range scs {
+		sc := &scs[i]
+		if sc.Name == "" || scns[sc.Name] != nil {
+			return nil, fmt.Errorf("scene %v has no name or one used before", i+1)
+		}
+		if len(sc.Lyrs) > 0 {
+			if err := CheckLyrs(sc.Lyrs, epcntrs); err != nil {
+				return nil, fmt.Errorf("scene %v: %v", sc.Name, err)
+			}
+		}
+		if sc.Trns != nil {
+			if err := sc.Trns.check(); err != nil {
+				return nil, fmt.Errorf("scene %v: %v", sc.Name, err)
+			}
+		}
+		scns[sc.Name] = sc
+	}
+	return scns, nil
+}
+
+func (tr Trns) check() error {
+	switch tr.Kind {
+	case TrnLinear, TrnEased:
+	case TrnWipe:
+		if (&LyrMask{Axis: tr.Axis}).axis() < 0 {
+			return fmt.Errorf("wipe axis '%v' is not x, y or z", tr.Axis)
+		}
+	default:
+		return fmt.Errorf("unknown transition '%v'", tr.Kind)
+	}
+	if tr.Dur < 0 {
+		return errors.New("transition duration is negative")
+	}
+	return nil
+}
+
+// ScnDflt - scene of blnkr layers over black, there unless a scenes file
+// defines its own
+const ScnDflt = "default"
+
+// CurScns - scenes loaded from file, journaled so replays light the same
+var CurScns map[string]*Scene
+
+// LoadScns - read scenes from file next to the default scene & start in it
+func (blnkr *Blnkr) LoadScns(fn string) error {
+	var scns map[string]*Scene
+	if fn != "" {
+		var err error
+		if scns, err = ReadScns(fn, len(blnkr.Epcntrs)); err != nil {
+			return err
+		}
+	}
+	blnkr.SetScns(scns)
+	return nil
+}
+
+// SetScns - use scenes next to the default scene & start in it
+func (blnkr *Blnkr) SetScns(scns map[string]*Scene) {
+	CurScns = scns
+	all := map[string]*Scene{ScnDflt: {Name: ScnDflt}}
+	for nm, sc := range scns {
+		all[nm] = sc
+	}
+	blnkr.Scns = all
+	blnkr.Scn = all[ScnDflt]
+	blnkr.Stts.SetScns(ScnDflt, blnkr.ScnNames())
+}
+
+// ScnNames - sorted names of scenes
+func (blnkr *Blnkr) ScnNames() []string {
+	nms := []string{}
+	for nm := range blnkr.Scns {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	return nms
+}
+
+// SetScn - move to scene with its transition
+func (blnkr *Blnkr) SetScn(nm string) {
+	sc, has := blnkr.Scns[nm]
+	if !has {
+		log.Printf("ERROR: no scene '%v'", nm)
+		return
+	}
+	if sc == blnkr.Scn {
+		return
+	}
+	tr := DfltTrns
+	if sc.Trns != nil {
+		tr = *sc.Trns
+	}
+	log.Printf("scene %v, %v transition over %vms", nm, tr.Kind, tr.Dur)
+	from := blnkr.Scn
+	blnkr.Scn = sc
+	blnkr.Stts.SetScns(nm, nil)
+	blnkr.Fade(tr, from)
+}
+
+// Fade - start transition from scene, or from the leds as they are if nil
+// or another transition is running
+func (blnkr *Blnkr) Fade(tr Trns, from *Scene) {
+	if tr.Dur <= 0 {
+		blnkr.Trn = nil
+		return
+	}
+	tn := Trnstn{Trns: tr, Strt: NowMs(), From: from}
+	if blnkr.Trn != nil || from == nil || blnkr.Md != BlnkRun {
+		tn.From = nil
+		tn.snp = make(map[string]*[LampSize]FRGB)
+		for ip, lmp := range blnkr.Lmps {
+			snp := [LampSize]FRGB{}
+			for i := range snp {
+				snp[i] = lmp.Pnts[i].Lst
+			}
+			tn.snp[ip] = &snp
+		}
+	}
+	if tr.Kind == TrnWipe {
+		tn.ax = (&LyrMask{Axis: tr.Axis}).axis()
+		tn.mn, tn.mx = math.Inf(1), math.Inf(-1)
+		for _, lmp := range blnkr.Lmps {
+			for _, pnt := range lmp.Pnts {
+				tn.mn = math.Min(tn.mn, pnt.Crds[tn.ax])
+				tn.mx = math.Max(tn.mx, pnt.Crds[tn.ax])
+			}
+		}
+	}
+	blnkr.Trn = &tn
+}
+
+// weight of new look at pnt, t of the way through transition
+func (tn *Trnstn) weight(pnt *Pnt, t float64) float64 {
+	switch tn.Kind {
+	case TrnEased:
+		return t * t * (3 - 2*t)
+	case TrnWipe:
+		p := 0.0
+		if tn.mx > tn.mn {
+			p = (pnt.Crds[tn.ax] - tn.mn) / (tn.mx - tn.mn)
+		}
+		if tn.Rev {
+			p = 1 - p
+		}
+		return math.Max(0, math.Min(1, (t*(1+WipeFthr)-p)/WipeFthr))
+	}
+	return t
+}
+
+// trans - mix what was showing into the new look while a transition runs,
+// then keep the result for the next transition to start from
+func (blnkr *Blnkr) trans() {
+	if tn := blnkr.Trn; tn != nil {
+		t := float64(NowMs()-tn.Strt) / float64(tn.Dur)
+		if t >= 1 {
+			blnkr.Trn = nil
+		} else {
+			if tn.From != nil {
+				for _, lmp := range blnkr.Lmps {
+					for i := range lmp.Pnts {
+						lmp.Pnts[i].Lst = lmp.Pnts[i].F
+					}
+				}
+				blnkr.composite(tn.From) // render old scene into F, new one kept in Lst
+			}
+			for ip, lmp := range blnkr.Lmps {
+				for i := range lmp.Pnts {
+					pnt := &lmp.Pnts[i]
+					to, from := pnt.F, FRGB{}
+					if tn.From == nil {
+						from = tn.snp[ip][i]
+					} else {
+						to, from = pnt.Lst, pnt.F
+					}
+					w := tn.weight(pnt, t)
+					for c := 0; c < 3; c++ {
+						pnt.F[c] = from[c] + (to[c]-from[c])*w
+					}
+				}
+			}
+		}
+	}
+	for _, lmp := range blnkr.Lmps {
+		for i := range lmp.Pnts {
+			lmp.Pnts[i].Lst = lmp.Pnts[i].F
+		}
+	}
+}
+
+// scnCmd - if cmd switches scene, do it
+func (blnkr *Blnkr) scnCmd(cmd string) bool {
+	if !strings.HasPrefix(cmd, ScnCmd) {
+		return false
+	}
+	blnkr.SetScn(strings.TrimPrefix(cmd, ScnCmd))
+	return true
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// show programs a schedule entry can run
+const (
+	PrgOff  = "off"         // lamps blank
+	PrgIdle = "idle"        // attract only, touches dont wake it
+	PrgLive = "interactive" // waves from votes, attract when idle
+	PrgSeq  = "sequence"    // loop a frame recording
+)
+
+// ShwBrtFd - ms to fade between brightness levels of schedule entries
+const ShwBrtFd = 2000
+
+// ShwEntry - program to run on given days between start & end, local time;
+// an end before the start runs past midnight
+//
+//	{"days": ["fri", "sat"], "start": "10:00", "end": "22:30",
+//	 "program": "interactive", "brightness": 0.8}
+type ShwEntry struct {
+	Days     []string `json:"days,omitempty"` // mon..sun, every day if empty
+	Start    string   `json:"start"`          // hh:mm
+	End      string   `json:"end"`            // hh:mm
+	Program  string   `json:"program"`
+	Brt      *float64 `json:"brightness,omitempty"` // 0-1, 1 if missing
+	Sequence string   `json:"sequence,omitempty"`   // .blkr recording for sequence program
+	Scene    string   `json:"scene,omitempty"`      // scene to move to when the entry starts
+
+	strt, end int       // minutes after midnight
+	frms      []*RecFrm // loaded sequence frames
+}
+
+// ShwOvrd - program set by an operator over the schedule
+type ShwOvrd struct {
+	Program string   `json:"program"`
+	Brt     *float64 `json:"brightness,omitempty"`
+	Until   int64    `json:"until,omitempty"` // ms, 0 until cleared
+}
+
+// ShwStts - schedule entry or override running now
+type ShwStts struct {
+	Entry    int      `json:"entry"` // index in schedule, -1 if none matches
+	Program  string   `json:"program"`
+	Brt      float64  `json:"brightness"`
+	Ovrd     *ShwOvrd `json:"override"`
+	Sequence string   `json:"sequence,omitempty"`
+}
+
+// ShwSchd - time of day schedule of show programs, shared between blnkr &
+// the admin handlers
+type ShwSchd struct {
+	mu      sync.Mutex
+	Entries []ShwEntry
+	Ovrd    *ShwOvrd
+	Loc     *time.Location // zone entry times are in, local if nil
+}
+
+// ShwCfg - schedule entries & the utc offset they ran at, journaled so
+// replays run the same programs on any machine
+type ShwCfg struct {
+	Entries []ShwEntry `json:"entries"`
+	Offset  int        `json:"utc_offset"` // seconds east of utc
+}
+
+// CurShw - schedule in use, nil without entries
+var CurShw *ShwCfg
+
+var wkdys = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// LoadShw - read schedule from file, making sure its scenes exist, or run
+// without entries if fn is empty so operators can still override
+func (blnkr *Blnkr) LoadShw(fn string) error {
+	if fn == "" {
+		return blnkr.SetShw(&ShwSchd{})
+	}
+	ss, err := ReadShwSchd(fn)
+	if err != nil {
+		return err
+	}
+	log.Printf("loaded %v schedule entries from %v", len(ss.Entries), fn)
+	return blnkr.SetShw(ss)
+}
+
+// SetShw - run schedule, making sure its scenes exist
+func (blnkr *Blnkr) SetShw(ss *ShwSchd) error {
+	for i, e := range ss.Entries {
+		if e.Scene != "" && blnkr.Scns[e.Scene] == nil {
+			return fmt.Errorf("schedule entry %v scene '%v' is not in the scenes", i+1, e.Scene)
+		}
+	}
+	blnkr.Shw, CurShw = ss, nil
+	if len(ss.Entries) > 0 {
+		loc := ss.Loc
+		if loc == nil {
+			loc = time.Local
+		}
+		_, off := time.Unix(0, NowMs()*int64(time.Millisecond)).In(loc).Zone()
+		CurShw = &ShwCfg{Entries: ss.Entries, Offset: off}
+	}
+	return nil
+}
+
+// Schd - schedule of journaled entries in the zone they ran in
+func (sc *ShwCfg) Schd() (*ShwSchd, error) {
+	ss, err := NewShwSchd(sc.Entries)
+	if err != nil {
+		return nil, err
+	}
+	ss.Loc = time.FixedZone("journal", sc.Offset)
+	return ss, nil
+}
+
+// ReadShwSchd - read & check json array of schedule entries, loading
+// recordings of sequence entries
+func ReadShwSchd(fn string) (*ShwSchd, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var es []ShwEntry
+	if err := json.Unmarshal(b, &es); err != nil {
+		return nil, err
+	}
+	return NewShwSchd(es)
+}
+
+// NewShwSchd - check entries & load recordings of sequence entries
+func NewShwSchd(es []ShwEntry) (*ShwSchd, error) {
+	es = append([]ShwEntry{}, es...)
+	for i := range es {
+		if err := es[i].init(); err != nil {
+			return nil, fmt.Errorf("schedule entry %v: %v", i+1, err)
+		}
+	}
+	return &ShwSchd{Entries: es}, nil
+}
+
+// check entry & parse its times
+func (e *ShwEntry) init() error {
+	var err error
+	if e.strt, err = parseHM(e.Start); err != nil {
+		return err
+	}
+	if e.end, err = parseHM(e.End); err != nil {
+		return err
+	}
+	for _, d := range e.Days {
+		if _, has := wkdys[strings.ToLower(d)]; !has {
+			return fmt.Errorf("unknown day '%v'", d)
+		}
+	}
+	if err := checkPrg(e.Program, e.Brt); err != nil {
+		return err
+	}
+	if e.Program == PrgSeq {
+		if e.frms, err = loadSeq(e.Sequence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse hh:mm as minutes after midnight
+func parseHM(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time '%v', want hh:mm", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func checkPrg(prg string, brt *float64) error {
+	switch prg {
+	case PrgOff, PrgIdle, PrgLive, PrgSeq:
+	default:
+		return fmt.Errorf("unknown program '%v'", prg)
+	}
+	if brt != nil && (*brt < 0 || *brt > 1) {
+		return fmt.Errorf("brightness %v is not between 0 & 1", *brt)
+	}
+	return nil
+}
+
+// read every frame of a recording for looping
+func loadSeq(fn string) ([]*RecFrm, error) {
+	if fn == "" {
+		return nil, errors.New("sequence program needs a sequence recording")
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fr, err := NewFrmRdr(f)
+	if err != nil {
+		return nil, err
+	}
+	frms := []*RecFrm{}
+	for {
+		frm, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		frms = append(frms, frm)
+	}
+	if len(frms) == 0 {
+		return nil, fmt.Errorf("sequence %v has no frames", fn)
+	}
+	return frms, nil
+}
+
+// on - if entry runs at t
+func (e *ShwEntry) on(t time.Time) bool {
+	min := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if e.end <= e.strt { // past midnight, after midnight part belongs to day before
+		if min < e.end {
+			day = (day + 6) % 7
+		} else if min < e.strt {
+			return false
+		}
+	} else if min < e.strt || min >= e.end {
+		return false
+	}
+	if len(e.Days) == 0 {
+		return true
+	}
+	for _, d := range e.Days {
+		if wkdys[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Active - override, else first entry running at now ms, else interactive
+// at full brightness; the entry is nil unless it comes from the schedule
+func (ss *ShwSchd) Active(now int64) (ShwStts, *ShwEntry) {
+	stts := ShwStts{Entry: -1, Program: PrgLive, Brt: 1}
+	if ss == nil {
+		return stts, nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.Ovrd != nil && ss.Ovrd.Until > 0 && now >= ss.Ovrd.Until {
+		log.Printf("schedule override of %v ran out", ss.Ovrd.Program)
+		ss.Ovrd = nil
+	}
+	if o := ss.Ovrd; o != nil {
+		stts.Program, stts.Ovrd = o.Program, o
+		if o.Brt != nil {
+			stts.Brt = *o.Brt
+		}
+		return stts, nil
+	}
+
+	t := time.Unix(0, now*int64(time.Millisecond))
+	if ss.Loc != nil {
+		t = t.In(ss.Loc)
+	}
+	for i := range ss.Entries {
+		e := &ss.Entries[i]
+		if !e.on(t) {
+			continue
+		}
+		stts.Entry, stts.Program, stts.Sequence = i, e.Program, e.Sequence
+		if e.Brt != nil {
+			stts.Brt = *e.Brt
+		}
+		return stts, e
+	}
+	return stts, nil
+}
+
+// SetOvrd - run program over the schedule, nil to go back to it
+func (ss *ShwSchd) SetOvrd(o *ShwOvrd) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.Ovrd = o
+}
+
+// show - every second switch blnkr to the program running now, fade toward
+// its brightness every frame & play sequences; a mode an operator set holds
+// over the program until they run again
+func (blnkr *Blnkr) show() {
+	if blnkr.Frm%(1000/UpdateDelay) == 0 || blnkr.Prg == "" {
+		stts, e := blnkr.Shw.Active(NowMs())
+		if stts.Program != blnkr.Prg || stts.Entry != blnkr.ShwStts.Entry || stts.Ovrd != blnkr.ShwStts.Ovrd {
+			log.Printf("show program %v at brightness %v", stts.Program, stts.Brt)
+			if blnkr.Prg != "" {
+				blnkr.Fade(DfltTrns, nil)
+			}
+			blnkr.Prg = stts.Program
+			blnkr.Seq, blnkr.SeqStrt = nil, NowMs()
+			if e != nil {
+				blnkr.Seq = e.frms
+			}
+			md := BlnkRun
+			if blnkr.Prg == PrgOff {
+				md = BlnkBlank
+			}
+			blnkr.ShwMd = md
+			if blnkr.OprMd == "" {
+				blnkr.Md = md
+				blnkr.Stts.SetMd(md)
+			} else {
+				log.Printf("keeping operator mode %v over show mode %v", blnkr.OprMd, md)
+			}
+			blnkr.Stts.SetShw(stts)
+			if e != nil && e.Scene != "" {
+				blnkr.SetScn(e.Scene)
+			}
+		}
+		blnkr.ShwStts = stts
+	}
+
+	stp := float64(UpdateDelay) / ShwBrtFd
+	switch d := blnkr.ShwStts.Brt - blnkr.Brt; {
+	case d > stp:
+		blnkr.Brt += stp
+	case d < -stp:
+		blnkr.Brt -= stp
+	default:
+		blnkr.Brt = blnkr.ShwStts.Brt
+	}
+}
+
+// dim float buffer to program brightness
+func (blnkr *Blnkr) dim() {
+	if blnkr.Brt >= 1 {
+		return
+	}
+	for _, lmp := range blnkr.Lmps {
+		for i := 0; i < LampSize; i++ {
+			lmp.Pnts[i].F = lmp.Pnts[i].F.Dim(blnkr.Brt)
+		}
+	}
+}
+
+// play - put sequence frame due now into the calibrated buffers as recorded,
+// looping, so the power limit & output bit depth still apply
+func (blnkr *Blnkr) play() {
+	if blnkr.Prg != PrgSeq || len(blnkr.Seq) == 0 || blnkr.Md != BlnkRun {
+		return
+	}
+	frst, lst := blnkr.Seq[0].T, blnkr.Seq[len(blnkr.Seq)-1].T
+	t := frst + (NowMs()-blnkr.SeqStrt)%(lst-frst+UpdateDelay)
+	frm := blnkr.Seq[0]
+	for _, f := range blnkr.Seq {
+		if f.T > t {
+			break
+		}
+		frm = f
+	}
+	mx := float64(int(1)<<uint(frm.Bits) - 1)
+	for ip, lmp := range blnkr.Lmps {
+		clrs, has := frm.Clrs[ip]
+		if !has {
+			continue
+		}
+		for i := 0; i < LampSize; i++ {
+			f := FRGB{float64(clrs[i][0]) / mx, float64(clrs[i][1]) / mx, float64(clrs[i][2]) / mx}
+			lmp.Cal[i] = f.Dim(blnkr.Brt) // recorded as sent, already calibrated
+			lmp.Pnts[i].F = lmp.Cal[i]
+		}
+	}
+}
+
+// ShwHandlers - register schedule endpoint on default mux: GET /admin/schedule
+// lists entries & what runs now, POST sets an override like
+// {"program": "off", "until": 1700000000000} & DELETE clears it; overrides
+// are journaled so replays light the same
+func ShwHandlers(ss *ShwSchd, stts *Stts, jrnl *Jrnl) {
+	http.HandleFunc("/admin/schedule", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var o ShwOvrd
+			if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := checkPrg(o.Program, o.Brt); err != nil || o.Program == PrgSeq {
+				if err == nil {
+					err = errors.New("sequences only run from the schedule")
+				}
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("schedule override %v from %v", o.Program, r.RemoteAddr)
+			jrnl.Ovrd(&o)
+			ss.SetOvrd(&o)
+		case http.MethodDelete:
+			log.Printf("schedule override cleared from %v", r.RemoteAddr)
+			jrnl.Ovrd(nil)
+			ss.SetOvrd(nil)
+		}
+
+		stts, _ := ss.Active(NowMs())
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		writeJSON(w, struct {
+			Entries []ShwEntry `json:"entries"`
+			Active  ShwStts    `json:"active"`
+		}{ss.Entries, stts}, nil)
+	})
+}
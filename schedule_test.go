@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// unix ms of local time on fri 16 oct 2026 plus days
+func shwMs(day, hr, min int) int64 {
+	return time.Date(2026, 10, 16+day, hr, min, 0, 0, time.Local).UnixNano() / int64(time.Millisecond)
+}
+
+func TestParseHM(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{"00:00", 0, false},
+		{"10:30", 630, false},
+		{"23:59", 1439, false},
+		{"9:05", 545, false},
+		{"24:00", 0, true},
+		{"10:60", 0, true},
+		{"1030", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHM(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q: got %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestShwEntryInit(t *testing.T) {
+	brt, over := 0.5, 1.5
+	tests := []struct {
+		name string
+		e    ShwEntry
+		err  bool
+	}{
+		{"ok", ShwEntry{Days: []string{"Fri", "sat"}, Start: "10:00", End: "22:00", Program: PrgLive, Brt: &brt}, false},
+		{"past midnight", ShwEntry{Start: "22:00", End: "02:00", Program: PrgOff}, false},
+		{"bad day", ShwEntry{Days: []string{"friday"}, Start: "10:00", End: "22:00", Program: PrgLive}, true},
+		{"bad time", ShwEntry{Start: "10am", End: "22:00", Program: PrgLive}, true},
+		{"bad program", ShwEntry{Start: "10:00", End: "22:00", Program: "party"}, true},
+		{"bad brightness", ShwEntry{Start: "10:00", End: "22:00", Program: PrgIdle, Brt: &over}, true},
+		{"sequence without recording", ShwEntry{Start: "10:00", End: "22:00", Program: PrgSeq}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.e.init(); (err != nil) != tt.err {
+			t.Errorf("%v: err %v", tt.name, err)
+		}
+	}
+}
+
+func TestShwSchdActive(t *testing.T) {
+	dim := 0.3
+	ss := &ShwSchd{Entries: []ShwEntry{
+		{Days: []string{"fri", "sat"}, Start: "22:00", End: "02:00", Program: PrgIdle, Brt: &dim}, // late nights, past midnight
+		{Start: "10:00", End: "22:00", Program: PrgLive},                                          // every day
+		{Days: []string{"sun"}, Start: "08:00", End: "12:00", Program: PrgOff},                    // under the daily entry
+		{Start: "03:00", End: "03:00", Program: PrgOff},                                           // rest of the day
+	}}
+	for i := range ss.Entries {
+		if err := ss.Entries[i].init(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		now   int64
+		entry int
+		prg   string
+		brt   float64
+	}{
+		{"fri morning", shwMs(0, 10, 0), 1, PrgLive, 1},
+		{"fri last minute of day entry", shwMs(0, 21, 59), 1, PrgLive, 1},
+		{"fri night", shwMs(0, 22, 0), 0, PrgIdle, 0.3},
+		{"sat after midnight is fri night", shwMs(1, 1, 59), 0, PrgIdle, 0.3},
+		{"sat at end of night", shwMs(1, 2, 0), 3, PrgOff, 1},
+		{"sun after midnight is sat night", shwMs(2, 0, 30), 0, PrgIdle, 0.3},
+		{"mon after midnight, sun night not on", shwMs(3, 0, 30), 3, PrgOff, 1},
+		{"thu night not on", shwMs(-1, 23, 0), 3, PrgOff, 1},
+		{"sun morning, daily entry comes first", shwMs(2, 11, 0), 1, PrgLive, 1},
+		{"sun early", shwMs(2, 8, 30), 2, PrgOff, 1},
+		{"early hours fall to whole day entry", shwMs(0, 2, 59), 3, PrgOff, 1},
+	}
+	for _, tt := range tests {
+		stts, e := ss.Active(tt.now)
+		if stts.Entry != tt.entry || stts.Program != tt.prg || stts.Brt != tt.brt || e != &ss.Entries[tt.entry] {
+			t.Errorf("%v: got entry %v %v at %v", tt.name, stts.Entry, stts.Program, stts.Brt)
+		}
+	}
+
+	// nothing matching runs interactive at full brightness
+	ss.Entries = ss.Entries[:1]
+	if stts, e := ss.Active(shwMs(0, 12, 0)); stts.Entry != -1 || stts.Program != PrgLive || stts.Brt != 1 || e != nil {
+		t.Errorf("no entry: got %+v", stts)
+	}
+	var none *ShwSchd
+	if stts, _ := none.Active(shwMs(0, 23, 0)); stts.Program != PrgLive {
+		t.Errorf("no schedule: got %+v", stts)
+	}
+}
+
+func TestShwSchdOvrd(t *testing.T) {
+	ss := &ShwSchd{Entries: []ShwEntry{{Start: "00:00", End: "00:00", Program: PrgIdle}}}
+	ss.Entries[0].init()
+	now := shwMs(0, 12, 0)
+	half := 0.5
+
+	ss.SetOvrd(&ShwOvrd{Program: PrgOff, Brt: &half, Until: now + 1000})
+	if stts, e := ss.Active(now); stts.Program != PrgOff || stts.Brt != 0.5 || stts.Ovrd == nil || stts.Entry != -1 || e != nil {
+		t.Errorf("override: got %+v", stts)
+	}
+	if stts, _ := ss.Active(now + 1000); stts.Program != PrgIdle || stts.Ovrd != nil || ss.Ovrd != nil {
+		t.Errorf("override ran out: got %+v", stts)
+	}
+
+	ss.SetOvrd(&ShwOvrd{Program: PrgLive})
+	if stts, _ := ss.Active(now + 1e9); stts.Program != PrgLive {
+		t.Errorf("override without end: got %+v", stts)
+	}
+	ss.SetOvrd(nil)
+	if stts, _ := ss.Active(now); stts.Program != PrgIdle || stts.Entry != 0 {
+		t.Errorf("cleared: got %+v", stts)
+	}
+}
+
+func TestShwCfgSchd(t *testing.T) {
+	sc := &ShwCfg{Entries: []ShwEntry{{Start: "10:00", End: "12:00", Program: PrgOff}}, Offset: 3 * 3600}
+	b, err := json.Marshal(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsc ShwCfg
+	if err := json.Unmarshal(b, &jsc); err != nil {
+		t.Fatal(err)
+	}
+	ss, err := jsc.Schd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entry times are in the journaled zone wherever the replay runs
+	utc := func(hr int) int64 {
+		return time.Date(2026, 10, 16, hr, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	}
+	tests := []struct {
+		hr  int
+		prg string
+	}{{6, PrgLive}, {7, PrgOff}, {8, PrgOff}, {9, PrgLive}, {11, PrgLive}}
+	for _, tt := range tests {
+		if stts, _ := ss.Active(utc(tt.hr)); stts.Program != tt.prg {
+			t.Errorf("%v:00 utc: got %v, want %v", tt.hr, stts.Program, tt.prg)
+		}
+	}
+
+	if _, err := (&ShwCfg{Entries: []ShwEntry{{Start: "10", End: "12:00", Program: PrgOff}}}).Schd(); err == nil {
+		t.Errorf("bad entry: no error")
+	}
+}
+
+func TestShowMd(t *testing.T) {
+	defer func(c func() int64) { clck = c }(clck)
+	now := shwMs(0, 12, 0)
+	clck = func() int64 { return now }
+	blnkr := &Blnkr{Md: BlnkRun, Brt: 1, Shw: &ShwSchd{}}
+	frm := int64(0)
+
+	// each step runs a program over the schedule, after the operator sets a mode
+	tests := []struct {
+		name string
+		opr  string // operator mode set before the step, empty for none
+		prg  string
+		want string
+	}{
+		{"starts interactive", "", PrgLive, BlnkRun},
+		{"off blanks", "", PrgOff, BlnkBlank},
+		{"interactive runs", "", PrgLive, BlnkRun},
+		{"operator test kept", BlnkTest, PrgOff, BlnkTest},
+		{"and kept on", "", PrgLive, BlnkTest},
+		{"operator run follows schedule", BlnkRun, PrgIdle, BlnkRun},
+		{"schedule has it again", "", PrgOff, BlnkBlank},
+		{"operator blank kept", BlnkBlank, PrgIdle, BlnkBlank},
+		{"and kept over off", "", PrgOff, BlnkBlank},
+		{"and over interactive", "", PrgLive, BlnkBlank},
+		{"operator calibrate kept", BlnkClbr, PrgIdle, BlnkClbr},
+		{"operator run while off stays blank", BlnkRun, PrgOff, BlnkBlank},
+	}
+	for _, tt := range tests {
+		if tt.opr != "" {
+			blnkr.Cmd(tt.opr)
+		}
+		blnkr.Shw.SetOvrd(&ShwOvrd{Program: tt.prg})
+		blnkr.Frm = frm
+		blnkr.show()
+		frm += 1000 / UpdateDelay
+		if blnkr.Prg != tt.prg || blnkr.Md != tt.want {
+			t.Errorf("%v: program %v mode %v, want %v %v", tt.name, blnkr.Prg, blnkr.Md, tt.prg, tt.want)
+		}
+	}
+}
@@ -1,33 +1,115 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
+	"strings"
 	"time"
 )
 
-// CycleDelay - delay between cycling words in milliseconds
-const CycleDelay = 20000
-
 // listens for incoming udp packets on port 3333 and prints them to stdout
+// or runs an offline subcommand when one is given
 func main() {
 
-	// ordered list of vote station addresses & last beats
-	votestns := []int{101, 102, 103}
-	votestnbeats := make([]int64, len(votestns))
+	// run subcommand instead of server if one is named
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCmd(os.Args[1], os.Args[2:])
+		return
+	}
 
-	// open file to log word & vote events
-	// create wrdr to manage cycling words & writing events to json logfile
-	f, err := os.OpenFile("wordlog.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	sssn := flag.String("session", "", "session or campaign name stamped on every word log record")
+	wlfn := flag.String("wordlog", "wordlog.json", "word log file")
+	wlmax := flag.Int64("wordlog-max", 64<<20, "rotate word log at this many bytes, 0 never")
+	wldaily := flag.Bool("wordlog-daily", true, "rotate word log when the date changes")
+	wlsync := flag.Duration("wordlog-sync", 5*time.Second, "max delay before word log writes are fsynced")
+	pck := flag.String("pick", PckRandom, "word pick mode: random, fair (even time per word & slot), weighted or matchup (rank words by head to head votes)")
+	wghts := flag.String("weights", "", "word weights for weighted picks, like Curious=2,Present=0.5, over those in the pool file")
+	plfn := flag.String("pool", PoolFile, "json or csv word pool file")
+	rdfn := flag.String("rounds", "", "json file of show rounds, each a question with a word set, duration & lighting theme")
+	sltdly := flag.Duration("slot-delay", time.Duration(SlotDelay)*time.Millisecond, "mean time a word stays up in a slot, 0 cycles one slot at a time every 20s")
+	sltjttr := flag.Float64("slot-jitter", SlotJttr, "slot times vary by up to this fraction of slot-delay either way")
+	cycvts := flag.Int("cycle-votes", CycleVts, "cycle a slot after this many votes, 0 never")
+	ledma := flag.String("led-ma", "20", "mA an led draws per channel at full scale, one value or r,g,b")
+	lmpma := flag.Float64("lamp-ma", 0, "max estimated mA per lamp, frames are dimmed to stay under it, 0 unlimited")
+	ttlma := flag.Float64("total-ma", 0, "max estimated mA of every lamp together, 0 unlimited")
+	lyrfn := flag.String("layers", "", "json file of wave layers with blend modes, opacities & masks, empty for in-waves under vote waves")
+	outbits := flag.Int("out-bits", OutBits, "bit depth of colors sent to the lamps, 12 for teensy lamps, 8 for 8 bit protocols")
+	dthr := flag.Bool("dither", Dither, "temporally dither colors sent to the lamps so slow fades look smooth")
+	idle := flag.Duration("idle", time.Duration(IdleDelay)*time.Millisecond, "fade to attract after this long without touches, 0 never")
+	shwfn := flag.String("schedule", "", "json file of time of day show programs: off, idle, interactive or sequence, with brightness")
+	scnfn := flag.String("scenes", "", "json file of scenes, each wave layers over a background with a transition into it")
+	prtfn := flag.String("particles", "", "json file of particle settings for layers showing votes as particles")
+	dbfn := flag.String("db", "", "sqlite database to also store word log events in, empty to skip")
+	flag.Parse()
+	SssnTag = *sssn
+	PckMd = *pck
+	SlotDelay = sltdly.Nanoseconds() / 1000000
+	SlotJttr = *sltjttr
+	CycleVts = *cycvts
+	IdleDelay = idle.Nanoseconds() / 1000000
+	if SlotJttr < 0 || SlotJttr >= 1 {
+		log.Fatal("slot-jitter must be at least 0 & below 1")
+	}
+	if _, err := NewPckr(PckMd); err != nil {
+		log.Fatal(err)
+	}
+	lma, err := ParseLedMA(*ledma)
 	if err != nil {
 		log.Fatal(err)
 	}
-	wrdr := NewWrdr(votestns, f)
-	defer f.Close() // close word log file on exit
+	Pwr.LedMA = lma
+	if *lmpma < 0 || *ttlma < 0 {
+		log.Fatal("lamp-ma & total-ma must not be negative")
+	}
+	Pwr.LmpMA, Pwr.TtlMA = *lmpma, *ttlma
+	if *outbits < 1 || *outbits > 16 {
+		log.Fatal("out-bits must be between 1 & 16")
+	}
+	OutBits, Dither = *outbits, *dthr
+
+	// ordered list of vote station addresses
+	votestns := []int{101, 102, 103}
+
+	// load word pool, with enough words for every station slot
+	pws, err := ReadPool(*plfn)
+	switch {
+	case err == nil:
+		log.Printf("loaded %v words from %v", len(pws), *plfn)
+	case os.IsNotExist(err) && *plfn == PoolFile:
+		log.Printf("no %v, using built in word pool", PoolFile)
+		pws = CurPool()
+	default:
+		log.Fatal(err)
+	}
+	if err := CheckPool(pws, len(votestns)*2); err != nil {
+		log.Fatal(err)
+	}
+	if WghtOvrds, err = ParseWghts(*wghts); err != nil {
+		log.Fatal(err)
+	}
+	SetPool(pws, len(votestns)*2)
 
-	// read led position file and create bllnkr with data
+	// start first round before words are picked so they come from its set
+	var schd *RndSchd
+	if *rdfn != "" {
+		rds, err := ReadRounds(*rdfn)
+		if err == nil {
+			err = CheckRounds(rds, pws, len(votestns)*2)
+		}
+		if err != nil {
+			log.Fatalf("cant use rounds from %v: %v", *rdfn, err)
+		}
+		schd = &RndSchd{Rnds: rds, Slots: len(votestns) * 2}
+		schd.Start(0)
+	}
+
+	// read led position file and create bllnkr with data, lighting is loaded
+	// before the journal starts so it is journaled with the config
 	leddata, err := ioutil.ReadFile("led_locations.json")
 	fmt.Println(len(leddata))
 	if err != nil {
@@ -38,6 +120,28 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// calibrate output colors with file beside led positions if there is one
+	blnkr.ClbrFn = ClbrPath("led_locations.json")
+	blnkr.LoadClbr()
+	if err := blnkr.SetLyrs(*lyrfn); err != nil {
+		log.Fatal(err)
+	}
+
+	// scenes operators & the schedule can move between
+	if err := blnkr.LoadScns(*scnfn); err != nil {
+		log.Fatal(err)
+	}
+
+	// particles over the layers & scenes
+	if err := blnkr.LoadPrtcls(*prtfn); err != nil {
+		log.Fatal(err)
+	}
+
+	// run programs by time of day, operators can override from admin
+	if err := blnkr.LoadShw(*shwfn); err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Println("lamps:")
 	for ip, lmp := range blnkr.Lmps {
 		fmt.Println(ip)
@@ -47,6 +151,43 @@ func main() {
 		fmt.Println()
 	}
 
+	// seed word picks so the journal can reproduce them on replay
+	seed := time.Now().UnixNano()
+	rnd := rand.New(rand.NewSource(seed))
+
+	// open journal of every inbound event for replay
+	jrnl, err := NewJrnl(fmt.Sprintf("journal_%v.json", time.Now().Format("20060102_150405")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer jrnl.Close()
+	blnkr.Strt = jrnl.Start(seed, votestns) // frame 0 at journal start so replay lands events on the same frames
+
+	// open rotating file to log word & vote events
+	// create wrdr to manage cycling words & writing events to json logfile
+	lw, err := NewLgWrtr(*wlfn, *wlmax, *wldaily, *wlsync)
+	if err != nil {
+		log.Fatal(err)
+	}
+	str := MultiStr{NewJSONStr(lw)}
+	var sqlstr *SQLStr
+	if *dbfn != "" {
+		sqlstr, err = NewSQLStr(*dbfn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		str = append(str, sqlstr)
+	}
+	wrdr := NewWrdr(votestns, str, rnd)
+	defer str.Close() // close word log file & database on exit
+
+	// repeat session start at the top of every rotated word log
+	lw.Hdr, err = json.Marshal(wrdr.SssnRec())
+	if err != nil {
+		log.Fatal(err)
+	}
+	lw.Hdr = append(lw.Hdr, '\n')
+
 	// buffered channel to pass vote colors to blnkr
 	rgbch := make(chan VtClr, 64)
 
@@ -56,12 +197,78 @@ func main() {
 	// buffered channel to receive websocket data clients
 	dch := make(chan DataClient, 16)
 
-	// index of connected clients
-	dcdx := make(map[string]DataClient)
-
 	// channel to trigger word cycling
 	cych := make(chan bool)
-	go Metronome(cych, CycleDelay)
+	go Metronome(cych, Tick())
+
+	// channel to pass mode commands to blnkr
+	cmdch := make(chan string, 4)
+
+	// channel to pass operator commands from admin dashboard to hub
+	ach := make(chan string, 4)
+
+	// hub routes events between stations, wrdr, blnkr & data clients
+	hub := NewHub(votestns, wrdr, rgbch, cmdch)
+	hub.Jrnl = jrnl
+	hub.Schd = schd
+
+	// channel to pass round lighting themes to blnkr
+	thmch := make(chan *Theme, 4)
+	hub.Thmch = thmch
+	blnkr.Thm = CurThm
+
+	// channels to pass touches & current words to blnkr for attract
+	tchch := make(chan int, 64)
+	hub.Tchch = tchch
+	wrdch := make(chan []Wrd, 4)
+	hub.Wrdch = wrdch
+	blnkr.Wrds = append([]Wrd{}, wrdr.Wrds...)
+
+	// channel to pass word pools swapped in from admin to hub
+	pch := make(chan []PoolWrd, 1)
+
+	// status shared with admin dashboard
+	stts := NewStts(votestns)
+	stts.SetWrds(wrdr)
+	blnkr.Stts = stts
+	stts.SetScns(blnkr.Scn.Name, blnkr.ScnNames())
+	hub.Stts = stts
+	stts.SetPool(PoolWrds)
+	if schd != nil {
+		stts.SetRnd(schd.Stts())
+	}
+	AdminHandlers(stts, ach)
+	PoolHandlers(stts, pch, *plfn, len(votestns)*2)
+	ShwHandlers(blnkr.Shw, stts, jrnl)
+
+	// canned queries against event database
+	// & seed todays leaderboard & matchup ratings from earlier runs
+	if sqlstr != nil {
+		SQLHandlers(sqlstr)
+		y, m, d := time.Now().Date()
+		mdnt := time.Date(y, m, d, 0, 0, 0, 0, time.Local).UnixNano() / 1000000
+		cnts, err := sqlstr.WordCounts(mdnt)
+		if err != nil {
+			log.Printf("ERROR: cant seed tallies from %v: %v", *dbfn, err)
+		} else {
+			hub.Tllr.Seed(cnts)
+		}
+		if wrdr.Mtchr != nil {
+			rs, err := sqlstr.Results(mdnt)
+			if err != nil {
+				log.Printf("ERROR: cant seed matchup ratings from %v: %v", *dbfn, err)
+			} else {
+				wrdr.Mtchr.Seed(rs)
+			}
+		}
+	}
+	TllyHandlers(hub.Tllr)
+	MtchHandlers(wrdr.Mtchr)
+
+	// channel to stream rendered frames to browser previews
+	frmch := make(chan []byte, 4)
+	blnkr.Frmch = frmch
+	PreviewHandlers(blnkr.Leds, frmch)
 
 	// listen for teensy messages on udp port 3333 and pass them up channel
 	go TeensySocket(tch)
@@ -70,100 +277,33 @@ func main() {
 	go DataSocket(dch, tch)
 
 	// pass color channel to blnkr udpcast routine
-	go blnkr.Cast(rgbch)
+	go blnkr.Cast(rgbch, cmdch, thmch, tchch, wrdch)
 
 	// loop over channels & handle messages
-	for {
-		select {
-
-		// incoming teensy message channel
-		case tm := <-tch:
-
-			// log message
-			log.Printf("received: %+v", tm)
-
-			switch tm.Flavor {
-
-			case "touch_beat": // log heartbeat
-				var sdx = int(-1)
-				for i, src := range votestns {
-					if src == tm.Source {
-						sdx = i
-					}
-				}
-				if sdx < 0 {
-					log.Printf("ERROR: unrecognized touch beat source '%v'\n", tm.Source)
-				} else {
-					votestnbeats[sdx] = NowMs() // set last beat time to now
-				}
-
-			case "start_touch", "end_touch": // broadcast to data clients
-				wrdp, err := wrdr.LogTouch(tm.Source, tm.Flavor, tm.Choice)
-				if err != nil {
-					log.Printf("ERROR: cant log touch: %v", err)
-				} else if tm.Flavor == "end_touch" {
-					select {
-					case rgbch <- VtClr{tm.Source, wrdp.Clr}:
-					default:
-						log.Printf("ERROR: rgbch full!")
-					}
-				}
-
-				dm := DataMsg{
-					Source: tm.Source, 
-					Flavor: tm.Flavor, 
-					Choice: tm.Choice,
-					Word: wrdp.Str,
-					Color: []int{int(wrdp.Clr[0]), int(wrdp.Clr[1]), int(wrdp.Clr[2])},
-				}
-				bcastMsg(dm, dcdx)
-			}
-
-			fmt.Printf("@")
-
-		// incoming data client channel
-		case dc := <-dch:
-			log.Printf("new data client at %v", dc.Dest)
-			dcdx[dc.Dest] = dc // append data client to index
-
-			// initialize data client with current words
-			for i, wrd := range wrdr.Wrds {
-				src, chc := wrdr.DeDex(i)
-				dm := DataMsg{
-					Source: src,
-					Choice: chc,
-					Flavor: "new_word",
-					Word:   wrd.Str,
-					Color:  []int{int(wrd.Clr[0]), int(wrd.Clr[1]), int(wrd.Clr[2])},
-				}
-				select {
-				case dc.MsgCh <- dm:
-				default:
-					log.Printf("ERROR: msgch for %v full on init!?", dc.Dest)
-				}
-			}
-
-			fmt.Printf("$")
-
-		// cycle words at intervals
-		case _ = <-cych:
-			fmt.Printf("[")
-			dm := wrdr.CycleWrd() // pick a new word & gen message
-			bcastMsg(dm, dcdx)    // broadcast message to data clients
-			fmt.Printf("]")
-		}
-	}
+	hub.Run(tch, dch, cych, ach, pch)
 }
 
-func bcastMsg(dm DataMsg, dcdx map[string]DataClient) {
-	for dest, dc := range dcdx {
-		select {
-		case dc.MsgCh <- dm:
-		default:
-			log.Printf("ERROR: msgch for %v full!", dc.Dest)
-			close(dc.MsgCh)
-			delete(dcdx, dest)
-		}
+// run a named offline subcommand with its args
+func runCmd(name string, args []string) {
+	var err error
+	switch name {
+	case "render":
+		err = RenderCmd(args)
+	case "play":
+		err = PlayCmd(args)
+	case "replay":
+		err = ReplayCmd(args)
+	case "analyze":
+		err = AnalyzeCmd(args)
+	case "report":
+		err = ReportCmd(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command '%v'\n", name)
+		fmt.Fprintln(os.Stderr, "commands: render, play, replay, analyze, report")
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 }
 
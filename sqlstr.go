@@ -0,0 +1,361 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	_ "modernc.org/sqlite" // pure go sqlite driver, no cgo needed on site machines
+)
+
+// SQLBuf - # of records queued for the database writer, records past it are
+// dropped rather than holding up touch handling
+const SQLBuf = 1024
+
+// sqlPrgms - write ahead log so readers dont block the writer, & fsync only
+// at checkpoints, which can lose the last records on power loss but never
+// corrupts the database
+const sqlPrgms = "?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)"
+
+// sqlSchema - tables & indexes for word log events
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	session TEXT NOT NULL,
+	version TEXT NOT NULL,
+	config_hash TEXT NOT NULL,
+	stations TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS posts (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	session TEXT NOT NULL,
+	source INTEGER NOT NULL,
+	choice TEXT NOT NULL,
+	word TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS posts_time ON posts(time);
+CREATE INDEX IF NOT EXISTS posts_word ON posts(word);
+CREATE TABLE IF NOT EXISTS touches (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	session TEXT NOT NULL,
+	source INTEGER NOT NULL,
+	choice TEXT NOT NULL,
+	word TEXT NOT NULL,
+	flavor TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS touches_slot ON touches(source, choice, time);
+CREATE INDEX IF NOT EXISTS touches_word ON touches(word);
+CREATE TABLE IF NOT EXISTS votes (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	session TEXT NOT NULL,
+	source INTEGER NOT NULL,
+	choice TEXT NOT NULL,
+	word TEXT NOT NULL,
+	duration INTEGER,
+	opponent TEXT
+);
+CREATE INDEX IF NOT EXISTS votes_time ON votes(time);
+CREATE INDEX IF NOT EXISTS votes_word ON votes(word);
+CREATE INDEX IF NOT EXISTS votes_source ON votes(source);
+CREATE TABLE IF NOT EXISTS station_status (
+	source INTEGER PRIMARY KEY,
+	last_beat INTEGER,
+	beats INTEGER NOT NULL DEFAULT 0,
+	last_touch INTEGER
+);
+`
+
+// SQLStr - stores word log records in an embedded sqlite database, written
+// from its own routine so callers never wait on the disk
+type SQLStr struct {
+	db   *sql.DB
+	open map[tchKey]int64 // start time of open touches, to time votes
+	ops  chan sqlOp
+	done chan bool
+}
+
+// record or heartbeat queued for the writer
+type sqlOp struct {
+	lg   *WrdLg // nil for heartbeats
+	src  int
+	stmp int64
+}
+
+// NewSQLStr - open or create sqlite database file & its tables & start writer
+func NewSQLStr(fn string) (*SQLStr, error) {
+	db, err := sql.Open("sqlite", fn+sqlPrgms)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqlSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// databases from before matchup results were stored
+	_, err = db.Exec(`ALTER TABLE votes ADD COLUMN opponent TEXT`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		db.Close()
+		return nil, err
+	}
+	log.Printf("storing word log events in %v", fn)
+	ss := SQLStr{
+		db:   db,
+		open: make(map[tchKey]int64),
+		ops:  make(chan sqlOp, SQLBuf),
+		done: make(chan bool),
+	}
+	go ss.write()
+	return &ss, nil
+}
+
+// Log - queue record for the writer
+func (ss *SQLStr) Log(lg WrdLg) error {
+	select {
+	case ss.ops <- sqlOp{lg: &lg}:
+		return nil
+	default:
+		return errors.New("database writer is behind, record dropped")
+	}
+}
+
+// Beat - queue heartbeat for the writer
+func (ss *SQLStr) Beat(src int, stmp int64) error {
+	select {
+	case ss.ops <- sqlOp{src: src, stmp: stmp}:
+		return nil
+	default:
+		return errors.New("database writer is behind, beat dropped")
+	}
+}
+
+// write queued records until closed
+func (ss *SQLStr) write() {
+	for op := range ss.ops {
+		if op.lg == nil {
+			if err := ss.beat(op.src, op.stmp); err != nil {
+				log.Printf("ERROR: failed to store beat from %v: %v", op.src, err)
+			}
+			continue
+		}
+		if err := ss.log(*op.lg); err != nil {
+			log.Printf("ERROR: failed to store %v record: %v", op.lg.Flavor, err)
+		}
+	}
+	close(ss.done)
+}
+
+// log - insert record into the table for its flavor in one transaction
+// an end touch is also stored as a vote, timed from the open start touch
+func (ss *SQLStr) log(lg WrdLg) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	switch lg.Flavor {
+
+	case "session_start":
+		stns, _ := json.Marshal(lg.Stations)
+		_, err = tx.Exec(
+			`INSERT INTO sessions (time, session, version, config_hash, stations) VALUES (?, ?, ?, ?, ?)`,
+			lg.Time, lg.Session, lg.Version, lg.CfgHash, string(stns))
+		ss.open = make(map[tchKey]int64) // touches dont survive a restart
+
+	case "post":
+		_, err = tx.Exec(
+			`INSERT INTO posts (time, session, source, choice, word) VALUES (?, ?, ?, ?, ?)`,
+			lg.Time, lg.Session, lg.Source, lg.Choice, lg.Word)
+
+	case "start_touch", "end_touch":
+		_, err = tx.Exec(
+			`INSERT INTO touches (time, session, source, choice, word, flavor) VALUES (?, ?, ?, ?, ?, ?)`,
+			lg.Time, lg.Session, lg.Source, lg.Choice, lg.Word, lg.Flavor)
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec(
+			`INSERT INTO station_status (source, last_touch) VALUES (?, ?)
+			ON CONFLICT(source) DO UPDATE SET last_touch = excluded.last_touch`,
+			lg.Source, lg.Time)
+		if err != nil {
+			break
+		}
+
+		k := tchKey{lg.Source, lg.Choice}
+		if lg.Flavor == "start_touch" {
+			if _, has := ss.open[k]; !has {
+				ss.open[k] = lg.Time
+			}
+			break
+		}
+		var dur interface{} // null when the start was missed
+		if strt, has := ss.open[k]; has {
+			dur = lg.Time - strt
+			delete(ss.open, k)
+		}
+		var opp interface{} // null unless scored as a matchup
+		if lg.Opponent != "" {
+			opp = lg.Opponent
+		}
+		_, err = tx.Exec(
+			`INSERT INTO votes (time, session, source, choice, word, duration, opponent) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			lg.Time, lg.Session, lg.Source, lg.Choice, lg.Word, dur, opp)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// update station status with heartbeat
+func (ss *SQLStr) beat(src int, stmp int64) error {
+	_, err := ss.db.Exec(
+		`INSERT INTO station_status (source, last_beat, beats) VALUES (?, ?, 1)
+		ON CONFLICT(source) DO UPDATE SET last_beat = excluded.last_beat, beats = beats + 1`,
+		src, stmp)
+	return err
+}
+
+// Close - write out queued records & close database
+func (ss *SQLStr) Close() error {
+	close(ss.ops)
+	<-ss.done
+	return ss.db.Close()
+}
+
+// Tally - vote count & mean touch duration for a group
+type Tally struct {
+	Key    string  `json:"key"`
+	Votes  int     `json:"votes"`
+	MeanMs float64 `json:"mean_ms"`
+}
+
+// tallyKeys - group by expressions for canned tally queries
+var tallyKeys = map[string]string{
+	"word":    `word`,
+	"hour":    `strftime('%H', time / 1000, 'unixepoch', 'localtime')`,
+	"station": `source || ' ' || choice`,
+}
+
+// Tallies - votes grouped by word, hour or station, optionally for one session
+func (ss *SQLStr) Tallies(by string, sssn string) ([]Tally, error) {
+	key, has := tallyKeys[by]
+	if !has {
+		return nil, fmt.Errorf("cant tally by '%v'", by)
+	}
+	q := &strings.Builder{}
+	fmt.Fprintf(q, `SELECT %v AS k, COUNT(*), COALESCE(AVG(duration), 0) FROM votes`, key)
+	args := []interface{}{}
+	if sssn != "" {
+		q.WriteString(` WHERE session = ?`)
+		args = append(args, sssn)
+	}
+	q.WriteString(` GROUP BY k ORDER BY COUNT(*) DESC, k`)
+
+	rows, err := ss.db.Query(q.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tls := []Tally{}
+	for rows.Next() {
+		var t Tally
+		if err := rows.Scan(&t.Key, &t.Votes, &t.MeanMs); err != nil {
+			return nil, err
+		}
+		tls = append(tls, t)
+	}
+	return tls, rows.Err()
+}
+
+// WordCounts - votes per word since unix ms stamp
+func (ss *SQLStr) WordCounts(since int64) (map[string]int, error) {
+	rows, err := ss.db.Query(`SELECT word, COUNT(*) FROM votes WHERE time >= ? GROUP BY word`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cnts := make(map[string]int)
+	for rows.Next() {
+		var w string
+		var n int
+		if err := rows.Scan(&w, &n); err != nil {
+			return nil, err
+		}
+		cnts[w] = n
+	}
+	return cnts, rows.Err()
+}
+
+// Results - matchup winners & losers in order since unix ms stamp
+func (ss *SQLStr) Results(since int64) ([][2]string, error) {
+	rows, err := ss.db.Query(
+		`SELECT word, opponent FROM votes WHERE time >= ? AND opponent IS NOT NULL ORDER BY time, id`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rs := [][2]string{}
+	for rows.Next() {
+		var r [2]string
+		if err := rows.Scan(&r[0], &r[1]); err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, rows.Err()
+}
+
+// StnStts - stored status of a vote station
+type StnStts struct {
+	Source    int   `json:"source"`
+	LastBeat  int64 `json:"last_beat"`
+	Beats     int64 `json:"beats"`
+	LastTouch int64 `json:"last_touch"`
+}
+
+// Stations - stored status of every vote station
+func (ss *SQLStr) Stations() ([]StnStts, error) {
+	rows, err := ss.db.Query(
+		`SELECT source, COALESCE(last_beat, 0), beats, COALESCE(last_touch, 0) FROM station_status ORDER BY source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stts := []StnStts{}
+	for rows.Next() {
+		var s StnStts
+		if err := rows.Scan(&s.Source, &s.LastBeat, &s.Beats, &s.LastTouch); err != nil {
+			return nil, err
+		}
+		stts = append(stts, s)
+	}
+	return stts, rows.Err()
+}
+
+// SQLHandlers - register canned query endpoints on default mux:
+// /api/tallies?by=word|hour|station[&session=name] & /api/stations
+func SQLHandlers(ss *SQLStr) {
+	http.HandleFunc("/api/tallies", func(w http.ResponseWriter, r *http.Request) {
+		by := r.URL.Query().Get("by")
+		if by == "" {
+			by = "word"
+		}
+		tls, err := ss.Tallies(by, r.URL.Query().Get("session"))
+		writeJSON(w, tls, err)
+	})
+
+	http.HandleFunc("/api/stations", func(w http.ResponseWriter, r *http.Request) {
+		stts, err := ss.Stations()
+		writeJSON(w, stts, err)
+	})
+}
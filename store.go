@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// WrdStr - destination for word log records & station heartbeats
+type WrdStr interface {
+	Log(lg WrdLg) error             // session, post & touch records
+	Beat(src int, stmp int64) error // vote station heartbeat
+	Close() error
+}
+
+// JSONStr - writes word log records as json lines, heartbeats are not logged
+type JSONStr struct {
+	enc *json.Encoder
+	c   io.Closer // optional
+}
+
+// NewJSONStr - json lines store over writer, closing it on close if it can be
+func NewJSONStr(w io.Writer) *JSONStr {
+	js := JSONStr{enc: json.NewEncoder(w)}
+	if c, ok := w.(io.Closer); ok {
+		js.c = c
+	}
+	return &js
+}
+
+// Log - append record as a json line
+func (js *JSONStr) Log(lg WrdLg) error {
+	return js.enc.Encode(&lg)
+}
+
+// Beat - heartbeats stay out of the json word log
+func (js *JSONStr) Beat(src int, stmp int64) error {
+	return nil
+}
+
+// Close - close underlying writer
+func (js *JSONStr) Close() error {
+	if js.c == nil {
+		return nil
+	}
+	return js.c.Close()
+}
+
+// MultiStr - fans records out to several stores, logging failures of each
+type MultiStr []WrdStr
+
+// Log - log record to every store, returns first error
+func (ms MultiStr) Log(lg WrdLg) error {
+	var ferr error
+	for _, s := range ms {
+		if err := s.Log(lg); err != nil {
+			log.Printf("ERROR: failed to store %v record: %v", lg.Flavor, err)
+			if ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+// Beat - record heartbeat in every store, returns first error
+func (ms MultiStr) Beat(src int, stmp int64) error {
+	var ferr error
+	for _, s := range ms {
+		if err := s.Beat(src, stmp); err != nil {
+			log.Printf("ERROR: failed to store beat from %v: %v", src, err)
+			if ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+// Close - close every store, returns first error
+func (ms MultiStr) Close() error {
+	var ferr error
+	for _, s := range ms {
+		if err := s.Close(); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
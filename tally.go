@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TllyTopic - data client topic for live tally messages
+const TllyTopic = "tally"
+
+// TllyTop - # of words in leaderboard sent with each tally message
+const TllyTop = 5
+
+// TllyWndws - time windows for vote rates, longest last
+var TllyWndws = []time.Duration{time.Minute, 10 * time.Minute, time.Hour}
+
+// TllyEntry - votes for one word or station in a leaderboard
+type TllyEntry struct {
+	Key    string  `json:"key"`
+	Votes  int     `json:"votes"`
+	PerMin float64 `json:"per_min,omitempty"` // only for windowed boards
+}
+
+// TllyMsg - running counts sent to tally subscribers on every vote
+type TllyMsg struct {
+	Count int         `json:"count"` // votes today for the word just voted for
+	Total int         `json:"total"` // all votes today
+	Top   []TllyEntry `json:"top"`   // most chosen words today
+}
+
+// tllyVt - recent vote kept for windowed rates
+type tllyVt struct {
+	t    int64
+	wrd  string
+	sssn string
+}
+
+// Tllr - running vote counts per word, station & session
+type Tllr struct {
+	mu    sync.Mutex
+	Wrds  map[string]int            // since server start
+	Stns  map[string]int            // since server start, by "source choice"
+	Sssns map[string]map[string]int // by session then word
+	Day   string                    // local date of day counts
+	Today map[string]int            // today by word
+	rcnt  []tllyVt                  // votes within the longest window
+}
+
+// NewTllr - init empty tallies
+func NewTllr() *Tllr {
+	return &Tllr{
+		Wrds:  make(map[string]int),
+		Stns:  make(map[string]int),
+		Sssns: make(map[string]map[string]int),
+		Day:   lwDay(NowMs()),
+		Today: make(map[string]int),
+	}
+}
+
+// Seed - start today's counts from earlier runs, e.g. from the event database
+func (tl *Tllr) Seed(today map[string]int) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	for w, n := range today {
+		tl.Today[w] += n
+	}
+}
+
+// Vote - count vote for word at station & return message for subscribers
+func (tl *Tllr) Vote(src int, chc string, wrd string) TllyMsg {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	now := NowMs()
+
+	if d := lwDay(now); d != tl.Day { // new day, new leaderboard
+		tl.Day = d
+		tl.Today = make(map[string]int)
+	}
+
+	tl.Wrds[wrd]++
+	tl.Stns[fmt.Sprintf("%v %v", src, chc)]++
+	if tl.Sssns[SssnTag] == nil {
+		tl.Sssns[SssnTag] = make(map[string]int)
+	}
+	tl.Sssns[SssnTag][wrd]++
+	tl.Today[wrd]++
+
+	tl.rcnt = append(tl.rcnt, tllyVt{now, wrd, SssnTag})
+	tl.prune(now)
+
+	tm := TllyMsg{Count: tl.Today[wrd], Top: board(tl.Today, 0)}
+	for _, n := range tl.Today {
+		tm.Total += n
+	}
+	if len(tm.Top) > TllyTop {
+		tm.Top = tm.Top[:TllyTop]
+	}
+	return tm
+}
+
+// drop recent votes older than the longest window
+func (tl *Tllr) prune(now int64) {
+	oldest := now - TllyWndws[len(TllyWndws)-1].Nanoseconds()/1000000
+	i := 0
+	for i < len(tl.rcnt) && tl.rcnt[i].t < oldest {
+		i++
+	}
+	tl.rcnt = tl.rcnt[i:]
+}
+
+// sort counts into leaderboard, with per minute rates if window is set
+func board(cnts map[string]int, wndw time.Duration) []TllyEntry {
+	es := []TllyEntry{}
+	for k, n := range cnts {
+		e := TllyEntry{Key: k, Votes: n}
+		if wndw > 0 {
+			e.PerMin = float64(n) / wndw.Minutes()
+		}
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Votes != es[j].Votes {
+			return es[i].Votes > es[j].Votes
+		}
+		return es[i].Key < es[j].Key
+	})
+	return es
+}
+
+// Board - leaderboard of words for window: today, all, session or a
+// duration up to the longest rate window like 10m
+func (tl *Tllr) Board(wndw string, sssn string) ([]TllyEntry, error) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	switch wndw {
+	case "", "today":
+		if lwDay(NowMs()) != tl.Day {
+			return []TllyEntry{}, nil
+		}
+		return board(tl.Today, 0), nil
+	case "all":
+		return board(tl.Wrds, 0), nil
+	case "session":
+		return board(tl.Sssns[sssn], 0), nil
+	case "stations":
+		return board(tl.Stns, 0), nil
+	}
+
+	d, err := time.ParseDuration(wndw)
+	if err != nil || d <= 0 || d > TllyWndws[len(TllyWndws)-1] {
+		return nil, fmt.Errorf("window must be today, all, session, stations or a duration up to %v", TllyWndws[len(TllyWndws)-1])
+	}
+	now := NowMs()
+	tl.prune(now)
+	since := now - d.Nanoseconds()/1000000
+	cnts := make(map[string]int)
+	for _, v := range tl.rcnt {
+		if v.t >= since && (sssn == "" || v.sssn == sssn) {
+			cnts[v.wrd]++
+		}
+	}
+	return board(cnts, d), nil
+}
+
+// TllyHandlers - register leaderboard endpoint on default mux:
+// /api/leaderboard?window=today|all|session|stations|<duration>[&session=name]
+func TllyHandlers(tl *Tllr) {
+	http.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		es, err := tl.Board(r.URL.Query().Get("window"), r.URL.Query().Get("session"))
+		writeJSON(w, es, err)
+	})
+}
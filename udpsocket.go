@@ -17,8 +17,17 @@ type TeensyMsg struct {
 	Source int    `json:"source"`
 	Flavor string `json:"flavor"`
 	Choice string `json:"choice"`
+	Topic  string `json:"topic,omitempty"` // for subscribe & unsubscribe from data clients
+	Via    string `json:"-"`               // ViaUDP | ViaWS, set by the receiving socket
+	Dest   string `json:"-"`               // data client address for ws messages
 }
 
+// origins of teensy messages
+const (
+	ViaUDP = "udp" // from a teensy vote station
+	ViaWS  = "ws"  // injected by a websocket data client
+)
+
 // TeensySocket - listens for incoming teensy messages over udp on port 3333
 // converts json to teensymsg struct & sends up channel
 func TeensySocket(ch chan TeensyMsg) {
@@ -49,6 +58,7 @@ func TeensySocket(ch chan TeensyMsg) {
 			if err != nil {
 				log.Printf("ERROR unmarshalling %v: %v", string(buffer[:msgsize]), err)
 			} else {
+				msg.Via = ViaUDP
 
 				// send message up channel
 				select {
@@ -12,16 +12,23 @@ import (
 // DataMsg - for sending messages to data clients:
 // {
 // 	"source": "<last digit of teensy ip address>",
-// 	"flavor": "start_touch" | "end_touch" | "new_word",
+// 	"flavor": "start_touch" | "end_touch" | "new_word" | "tally" | "ranking" | "question",
 // 	"choice": "left" | "right"
 //  "word": "<new word as string>"
+//  "tally": {...} only for tally messages
+//  "ranking": [...] only for ranking messages
+//  "question": "<round question>" only for question messages
 // }
+// clients get tally or ranking messages after sending {"flavor": "subscribe", "topic": "tally" | "ranking"}
 type DataMsg struct {
-	Source int    `json:"source"`
-	Flavor string `json:"flavor"`
-	Choice string `json:"choice"`
-	Word   string `json:"word"`
-	Color  []int  `json:"color"`
+	Source   int         `json:"source"`
+	Flavor   string      `json:"flavor"`
+	Choice   string      `json:"choice"`
+	Word     string      `json:"word"`
+	Color    []int       `json:"color"`
+	Tally    *TllyMsg    `json:"tally,omitempty"`
+	Ranking  []MtchEntry `json:"ranking,omitempty"`
+	Question string      `json:"question,omitempty"`
 }
 
 // DataClient - holds channel to goroutine with websocket connection to client
@@ -61,6 +68,8 @@ func DataSocket(dch chan DataClient, tch chan TeensyMsg) {
 				if err != nil {
 					log.Printf("ERROR unmarshalling %v: %v", reply, err)
 				} else {
+					msg.Via = ViaWS
+					msg.Dest = dc.Dest
 
 					// send message down teensy message channel
 					select {
@@ -1,11 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
-	"os"
 	"time"
 )
 
@@ -15,7 +14,7 @@ type Wrd struct {
 	Clr RGB
 }
 
-// WrdPool - words to use for dataviz
+// WrdPool - words to use for dataviz, replaced by the pool file if there is one
 var WrdPool = []Wrd{
 	{"Analytical", RGB{0xc90, 0x910, 0xd30}},
 	{"Inquisitive", RGB{0xc86, 0x4b0, 0xff0}},
@@ -36,73 +35,160 @@ var WrdPool = []Wrd{
 // PostDelay - dataclient word cycle timeout
 var PostDelay = int64(10000)
 
+// Version - server version, set at build with -ldflags "-X main.Version=..."
+var Version = "dev"
+
+// SssnTag - session or campaign name stamped on every word log record
+var SssnTag = ""
+
 // Wrdr - manages word cycling & vote logging
 type Wrdr struct {
 	Srcs    []int
 	Wrds    []Wrd
 	LstWrds []Wrd
 	Stmps   []int64
-	Lgr     *json.Encoder
+	Due     []int64    // time each slot is next cycled
+	Vts     []int      // votes per slot since its word was posted
+	Opn     []OpnTch   // touch in progress per slot
+	Str     WrdStr     // where word log records go
+	Rnd     *rand.Rand // seeded source for word picks
+	Pckr    Pckr       // strategy for slots to cycle & words to post
+	Mtchr   *Mtchr     // pairs & ratings, nil unless picking matchups
 }
 
 // WrdLg - json record for logging word posts & touches
 type WrdLg struct {
-	Word   string `json:"word"`
-	Flavor string `json:"flavor"`
-	Source int    `json:"source"`
-	Choice string `json:"choice"`
-	Time   int64  `json:"time"`
+	Word    string `json:"word"`
+	Flavor  string `json:"flavor"`
+	Source  int    `json:"source"`
+	Choice  string `json:"choice"`
+	Time    int64  `json:"time"`
+	Session string `json:"session,omitempty"`
+	Qstn    string `json:"question,omitempty"` // question of the round
+
+	// only set on end_touch records scored in matchup mode, the word that lost
+	Opponent string `json:"opponent,omitempty"`
+
+	// only set on session_start records
+	Version  string `json:"version,omitempty"`
+	CfgHash  string `json:"config_hash,omitempty"`
+	Stations []int  `json:"stations,omitempty"`
 }
 
-// NewWrdr - init wrdr with list of vote station sources & logfile
-func NewWrdr(srcs []int, lgf *os.File) Wrdr {
+// NewWrdr - init wrdr with list of vote station sources, log store & random source
+func NewWrdr(srcs []int, str WrdStr, rnd *rand.Rand) Wrdr {
 	wrdln := len(srcs) * 2
 	w := Wrdr{
 		Srcs:    srcs[:],
 		Wrds:    make([]Wrd, wrdln),
 		LstWrds: make([]Wrd, wrdln),
 		Stmps:   make([]int64, wrdln),
-		Lgr:     json.NewEncoder(lgf),
+		Due:     make([]int64, wrdln),
+		Vts:     make([]int, wrdln),
+		Opn:     make([]OpnTch, wrdln),
+		Str:     str,
+		Rnd:     rnd,
 	}
+	pckr, err := NewPckr(PckMd)
+	if err != nil {
+		log.Printf("ERROR: %v, picking at random", err)
+		pckr = RndPckr{}
+	}
+	w.Pckr = pckr
+	w.Mtchr, _ = pckr.(*Mtchr)
+	w.Mtchr.SetPool(WrdPool)
 
-	for i := 0; i < wrdln; i++ {
-
-		// set initial word values and time stamps
-		wrd := w.PickWrd()
-		stmp := NowMs()
-		w.Wrds[i] = wrd
-		w.Stmps[i] = stmp
+	// mark start of session so restarts are visible in the log
+	w.Str.Log(w.SssnRec())
 
-		// log posted words
-		w.LogPost(i, wrd.Str, stmp)
+	// set initial words & time stamps, a station at a time for pairs
+	grp := 1
+	if w.Pckr.Pairs() {
+		grp = 2
+	}
+	for i := 0; i < wrdln; i += grp {
+		dxs := []int{}
+		for j := i; j < i+grp; j++ {
+			dxs = append(dxs, j)
+		}
+		w.Repost(dxs, NowMs())
 	}
 
 	return w
 }
 
-// PickWrd - return randomish word not in current words list from pool
-func (w Wrdr) PickWrd() Wrd {
-	for {
-		nwwrd := WrdPool[rand.Intn(len(WrdPool))] // pick random word from pool
-		isnw := bool(true)
+// cands - pool words for slots that are not up anywhere else, or if too few
+// of those are left, words not up on the other side of the same station
+func (w Wrdr) cands(dxs []int) []Wrd {
+	excl := make(map[string]bool)
+	for _, wrd := range w.Wrds {
+		excl[wrd.Str] = true
+	}
+	cs := w.free(excl)
+	if len(cs) >= len(dxs) {
+		return cs
+	}
 
-		for _, wrd := range w.Wrds { // check if word is displayed now
-			if nwwrd.Str == wrd.Str {
-				isnw = false
-			}
+	log.Printf("ERROR: only %v free words for %v slots, reusing words from other stations", len(cs), len(dxs))
+	excl = make(map[string]bool)
+	for _, wrddx := range dxs {
+		if sib := wrddx ^ 1; !hasDx(dxs, sib) {
+			excl[w.Wrds[sib].Str] = true
 		}
-		if isnw {
-			return nwwrd
+	}
+	return w.free(excl)
+}
+
+// pool words not in excl
+func (w Wrdr) free(excl map[string]bool) []Wrd {
+	cs := []Wrd{}
+	for _, wrd := range WrdPool {
+		if !excl[wrd.Str] {
+			cs = append(cs, wrd)
 		}
 	}
+	return cs
 }
 
-// CycleWrd - randomly change one of the current words & log change
-func (w Wrdr) CycleWrd() DataMsg {
-	wrddx := rand.Intn(len(w.Wrds)) // pick random vote station to cycle word for
+func hasDx(dxs []int, wrddx int) bool {
+	for _, d := range dxs {
+		if d == wrddx {
+			return true
+		}
+	}
+	return false
+}
+
+// Repost - post newly picked words to slots from stamp on & restart their
+// timers, slots left without a word keep theirs
+func (w Wrdr) Repost(dxs []int, stmp int64) []DataMsg {
+	wrds := w.Pckr.Pick(w, w.cands(dxs), len(dxs))
+	if len(wrds) < len(dxs) {
+		log.Printf("ERROR: no words left to post to slots %v", dxs[len(wrds):])
+	}
+	dms := []DataMsg{}
+	for j, wrd := range wrds {
+		dms = append(dms, w.post(dxs[j], wrd, stmp))
+	}
+	due := w.nxtDue(stmp)
+	for _, wrddx := range dxs {
+		w.Due[wrddx] = due
+		w.Vts[wrddx] = 0
+	}
+	return dms
+}
 
-	nwwrd := w.PickWrd()
+// CycleWrd - change the words in the slots the pick strategy chooses, a
+// single slot or both sides of a station, & log changes
+func (w Wrdr) CycleWrd() []DataMsg {
+	dxs := w.Pckr.Slots(w)      // pick vote station slots to cycle words for
 	stmp := NowMs() + PostDelay // stamp in future after post delay
+	return w.Repost(dxs, stmp)
+}
+
+// swap word into slot from stamp on, log it & gen message for data clients
+func (w Wrdr) post(wrddx int, nwwrd Wrd, stmp int64) DataMsg {
+	w.Pckr.Posted(wrddx, w.Wrds[wrddx], w.Stmps[wrddx], stmp)
 	w.LstWrds[wrddx] = w.Wrds[wrddx]
 	w.Wrds[wrddx] = nwwrd
 	w.Stmps[wrddx] = stmp
@@ -122,16 +208,32 @@ func (w Wrdr) CycleWrd() DataMsg {
 func (w Wrdr) LogPost(wrddx int, nwwrd string, stmp int64) {
 	src, chc := w.DeDex(wrddx)
 	lg := WrdLg{
-		Word:   nwwrd,
-		Flavor: "post",
-		Source: src,
-		Choice: chc,
-		Time:   stmp,
+		Word:    nwwrd,
+		Flavor:  "post",
+		Source:  src,
+		Choice:  chc,
+		Time:    stmp,
+		Session: SssnTag,
+		Qstn:    Qstn,
+	}
+	w.Str.Log(lg)
+}
+
+// SssnRec - session start record with version, config hash & stations
+func (w Wrdr) SssnRec() WrdLg {
+	return WrdLg{
+		Flavor:   "session_start",
+		Time:     NowMs(),
+		Session:  SssnTag,
+		Qstn:     Qstn,
+		Version:  Version,
+		CfgHash:  CurCfg(w.Srcs).Hash(),
+		Stations: w.Srcs,
 	}
-	w.Lgr.Encode(&lg)
 }
 
-// LogTouch - write touch event to json log file
+// LogTouch - write touch event to json log file, an end touch counts for the
+// word that was up when its touch started
 func (w Wrdr) LogTouch(src int, flvr string, chc string) (*Wrd, error) {
 	stmp := NowMs()
 	wrddx := w.Dex(src, chc)
@@ -140,23 +242,61 @@ func (w Wrdr) LogTouch(src int, flvr string, chc string) (*Wrd, error) {
 			"failed to log touch from unexpected source '%v' '%v'", src, chc)
 		return nil, errors.New(emsg)
 	}
-	wrd := w.Wrds[wrddx]
-	if stmp < w.Stmps[wrddx] { // check if word has loaded yet
-		wrd = w.LstWrds[wrddx] // if not register vote for last word
+	wrd := w.shwn(wrddx, stmp)
+	opp := w.shwn(wrddx^1, stmp)
+	scr := false // end of a held touch past stutter, scored in matchups
+
+	// keep what was up at touch start for the vote
+	switch flvr {
+	case "start_touch":
+		if !w.opn(wrddx, stmp) {
+			w.Opn[wrddx] = OpnTch{stmp, wrd, opp}
+		}
+	case "end_touch":
+		held := w.opn(wrddx, stmp)
+		if held {
+			wrd, opp = w.Opn[wrddx].Wrd, w.Opn[wrddx].Opp
+			scr = stmp-w.Opn[wrddx].Strt > StttrMs
+		}
+		if !held || scr { // stutter keeps touch open for its real end, like PairTchs
+			w.Opn[wrddx] = OpnTch{}
+		}
+		w.Vts[wrddx]++
 	}
 
 	lg := WrdLg{
-		Word:   wrd.Str,
-		Flavor: flvr,
-		Source: src,
-		Choice: chc,
-		Time:   stmp,
+		Word:    wrd.Str,
+		Flavor:  flvr,
+		Source:  src,
+		Choice:  chc,
+		Time:    stmp,
+		Session: SssnTag,
+		Qstn:    Qstn,
+	}
+
+	// score vote as a win over the other side of the station
+	if w.Mtchr != nil && scr && opp.Str != "" && opp.Str != wrd.Str {
+		lg.Opponent = opp.Str
+		w.Mtchr.Result(wrd.Str, lg.Opponent)
 	}
-	w.Lgr.Encode(&lg)
+	w.Str.Log(lg)
 
 	return &wrd, nil
 }
 
+// word shown in slot at stamp
+func (w Wrdr) shwn(wrddx int, stmp int64) Wrd {
+	if stmp < w.Stmps[wrddx] { // check if word has loaded yet
+		return w.LstWrds[wrddx] // if not register vote for last word
+	}
+	return w.Wrds[wrddx]
+}
+
+// LogBeat - record vote station heartbeat in log store
+func (w Wrdr) LogBeat(src int, stmp int64) {
+	w.Str.Beat(src, stmp)
+}
+
 // DeDex - get vote station source address & side from index
 func (w Wrdr) DeDex(wrddx int) (int, string) {
 	src := w.Srcs[wrddx/2]
@@ -180,7 +320,12 @@ func (w Wrdr) Dex(src int, chc string) int {
 	return -1
 }
 
+// clck - source of current time in ms, swapped for a virtual clock on replay
+var clck = func() int64 {
+	return time.Now().UnixNano() / 1000000
+}
+
 // NowMs - current unix epoch time in ms
 func NowMs() int64 {
-	return time.Now().UnixNano() / 1000000
+	return clck()
 }